@@ -2,63 +2,391 @@ package aggregator
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
-	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/go-github/v52/github"
+	"github.com/jakecoffman/stldevs/config"
+	"github.com/jakecoffman/stldevs/db"
 	"github.com/jakecoffman/stldevs/db/sqlc"
 	"golang.org/x/oauth2"
 )
 
-//go:embed orgs.txt
-var orgList string
+// DefaultWorkers is the number of users refreshed in parallel when none is configured.
+const DefaultWorkers = 4
 
 type Aggregator struct {
-	client  *github.Client
-	queries *sqlc.Queries
-	running bool
+	db        *sql.DB
+	region    config.Region
+	locations *locationClassifier
+	client    *github.Client
+	queries   *sqlc.Queries
+	budget    *rateBudget
+	workers   int
+	includes  *includeQueue
+	// holder identifies this process when it holds the run lease.
+	holder  string
+	running atomic.Bool
+	// apiCalls counts every request sent to GitHub, runs report the difference.
+	apiCalls atomic.Int64
 }
 
-func New(db *sql.DB, githubKey string) *Aggregator {
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: githubKey})
+// New creates an Aggregator for the configured region that refreshes users with
+// cfg.Workers workers, or DefaultWorkers if that is not positive.
+func New(db *sql.DB, cfg *config.Config) *Aggregator {
+	queries := sqlc.New(db)
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.GithubKey})
 	client := oauth2.NewClient(context.Background(), ts)
-	return &Aggregator{client: github.NewClient(client), queries: sqlc.New(db)}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	a := &Aggregator{
+		db:        db,
+		region:    cfg.Region,
+		locations: newLocationClassifier(cfg.Region),
+		queries:   queries,
+		budget:    &rateBudget{},
+		workers:   workers,
+		includes:  newIncludeQueue(),
+		holder:    leaseHolder(),
+	}
+	counting := &countingTransport{base: client.Transport, calls: &a.apiCalls}
+	client.Transport = &cachingTransport{base: counting, store: queries}
+	a.client = github.NewClient(client)
+	return a
+}
+
+type RunOptions struct {
+	// Mode picks the users the run refreshes, ModeFull if empty.
+	Mode string
+	// Login is the user a ModeUser run refreshes.
+	Login string
+	// OlderThan is how long ago a ModeStale run's users were last refreshed.
+	OlderThan time.Duration
+	// Fresh starts a new full run instead of resuming one left unfinished.
+	Fresh bool
 }
 
-func (a *Aggregator) Run() {
-	if a.running {
+// Run refreshes the users picked by opts.Mode, by default discovering every
+// user in the region. Unless opts.Fresh is set, a full run left unfinished by a
+// previous process is resumed, skipping the users it already refreshed.
+// Cancelling ctx stops the run and records it as aborted, and it is resumed
+// like any other unfinished run. Only one run happens at a time, across every
+// process sharing the database.
+func (a *Aggregator) Run(ctx context.Context, opts RunOptions) {
+	if !a.running.CompareAndSwap(false, true) {
 		log.Println("Already running, aborting run.")
 		return
 	}
-	log.Println("Run started")
-	a.running = true
-	defer func() { a.running = false }()
-	if err := a.insertRunLog(); err != nil {
-		log.Println(err)
+	defer a.running.Store(false)
+	ctx, release, err := a.acquireLease(ctx)
+	if errors.Is(err, ErrRunInProgress) {
+		log.Println("Another process is running, aborting run.")
 		return
 	}
-	log.Println("Run log inserted")
-	users, err := FindInStl(a.client, "user")
 	if err != nil {
-		log.Println(err)
 		return
 	}
-	for _, org := range strings.Split(orgList, "\n") {
+	defer release()
+	if opts.Mode == "" {
+		opts.Mode = ModeFull
+	}
+	log.Println("Run started", opts.Mode)
+
+	// with the lease held, a run still marked running was left by a process that died
+	if err = a.abortUnfinishedRuns(ctx); err != nil {
+		return
+	}
+	var r *run
+	var users map[string]struct{}
+	if opts.Mode == ModeFull && !opts.Fresh {
+		if r, users, err = a.resumeRun(ctx); err != nil {
+			return
+		}
+	}
+
+	if r != nil {
+		log.Println("Resuming run", r.id, "with", len(users), "users left")
+	} else {
+		if r, err = a.startRun(ctx, opts.Mode); err != nil {
+			return
+		}
+		log.Println("Run", r.id, "inserted")
+		if users, err = a.targets(ctx, r, opts); err != nil {
+			a.recordError(ctx, r, "", err)
+			a.finishRun(ctx, r, failedOrAborted(ctx))
+			return
+		}
+		if err = a.checkpointUsers(ctx, r, users); err != nil {
+			a.recordError(ctx, r, "", err)
+			a.finishRun(ctx, r, failedOrAborted(ctx))
+			return
+		}
+		r.usersDiscovered.Store(int32(len(users)))
+	}
+	a.refreshAll(ctx, r, users, opts.Mode != ModeDiscover)
+	if ctx.Err() != nil {
+		a.finishRun(ctx, r, StatusAborted)
+		return
+	}
+	a.downsampleSnapshots(ctx)
+	a.finishRun(ctx, r, StatusSucceeded)
+}
+
+// failedOrAborted is the status of a run that stopped early, which is only a
+// failure if it wasn't cancelled.
+func failedOrAborted(ctx context.Context) string {
+	if ctx.Err() != nil {
+		return StatusAborted
+	}
+	return StatusFailed
+}
+
+// targets returns the users a new run refreshes, never those who opted out.
+func (a *Aggregator) targets(ctx context.Context, r *run, opts RunOptions) (map[string]struct{}, error) {
+	users, err := a.modeTargets(ctx, r, opts)
+	if err != nil {
+		return nil, err
+	}
+	return a.withoutOptOuts(ctx, users)
+}
+
+func (a *Aggregator) modeTargets(ctx context.Context, r *run, opts RunOptions) (map[string]struct{}, error) {
+	switch opts.Mode {
+	case ModeFull, ModeDiscover:
+		return a.discover(ctx, r)
+	case ModeUser:
+		if opts.Login == "" {
+			return nil, errors.New("no login to refresh")
+		}
+		return map[string]struct{}{opts.Login: {}}, nil
+	case ModeOrgs:
+		orgs, err := a.orgs(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		return toSet(orgs), nil
+	case ModeStale:
+		stale, err := a.queries.StaleUsers(ctx, sql.NullTime{Time: time.Now().Add(-opts.OlderThan), Valid: true})
+		if err != nil {
+			log.Println("Failed listing stale users", err)
+			return nil, err
+		}
+		return toSet(stale), nil
+	}
+	return nil, fmt.Errorf("unknown run mode %q", opts.Mode)
+}
+
+func toSet(logins []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(logins))
+	for _, login := range logins {
+		set[login] = struct{}{}
+	}
+	return set
+}
+
+// discover finds the users in the region and adds the tracked organizations
+// and the users who opted in. Users already gathered are included too, so the
+// ones who moved away, which the search no longer finds, are reclassified.
+func (a *Aggregator) discover(ctx context.Context, r *run) (map[string]struct{}, error) {
+	users, err := FindInStl(ctx, a.client, a.region, "user")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	known, err := a.queries.RegionUsers(ctx)
+	if err != nil {
+		log.Println("Failed listing gathered users", err)
+		return nil, err
+	}
+	for _, login := range known {
+		users[login] = struct{}{}
+	}
+	orgs, err := a.orgs(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
 		users[org] = struct{}{}
 	}
-	for user := range users {
-		log.Println("Adding/Updating", user)
-		if err = a.Add(user); err != nil {
-			log.Println(err)
+	optIns, err := a.queries.OptInLogins(ctx)
+	if err != nil {
+		log.Println("Failed listing opted in users", err)
+		return nil, err
+	}
+	for _, login := range optIns {
+		users[login] = struct{}{}
+	}
+	return users, nil
+}
+
+// orgs returns the organizations in the registry, first importing the region's
+// org list file if one is configured and it changed since it was last
+// imported. A dry run includes the file's orgs without importing them.
+func (a *Aggregator) orgs(ctx context.Context, r *run) ([]string, error) {
+	var listed []string
+	if a.region.Orgs != "" {
+		orgs, hash, err := readOrgList(a.region.Orgs)
+		if err != nil {
+			return nil, err
+		}
+		if r.dryRun() {
+			imported, err := a.queries.OrgListImported(ctx, hash)
+			if err != nil {
+				log.Println("Failed checking org list import", err)
+				return nil, err
+			}
+			if !imported {
+				listed = orgs
+			}
+		} else if err = a.importOrgs(ctx, a.region.Orgs, hash, orgs); err != nil {
+			return nil, err
+		}
+	}
+	orgs, err := a.queries.OrgLogins(ctx)
+	if err != nil {
+		log.Println("Failed listing org registry", err)
+		return nil, err
+	}
+	return append(orgs, listed...), nil
+}
+
+// readOrgList reads a newline separated list of organizations, returning them
+// with the hash of the file.
+func readOrgList(path string) ([]string, []byte, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		log.Println("Failed reading org list", err)
+		return nil, nil, err
+	}
+	var orgs []string
+	for _, org := range strings.Split(string(contents), "\n") {
+		if org = strings.TrimSpace(org); org != "" {
+			orgs = append(orgs, org)
+		}
+	}
+	hash := sha256.Sum256(contents)
+	return orgs, hash[:], nil
+}
+
+// importOrgs adds the organizations listed in the file at path to the
+// registry, leaving the ones already there alone. Each version of the file is
+// only imported once, so orgs removed from the registry since aren't added
+// back until the file changes.
+func (a *Aggregator) importOrgs(ctx context.Context, path string, hash []byte, orgs []string) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+	queries := a.queries.WithTx(tx)
+	fresh, err := queries.InsertOrgImport(ctx, sqlc.InsertOrgImportParams{Hash: hash, Path: path, ImportedAt: time.Now()})
+	if err != nil {
+		log.Println("Failed recording org list import", err)
+		return err
+	}
+	if fresh == 0 {
+		return nil
+	}
+	reason := "imported from " + path
+	after, err := json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		added, err := queries.InsertOrg(ctx, sqlc.InsertOrgParams{
+			Login:     org,
+			Reason:    reason,
+			AddedBy:   "config",
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			log.Println("Failed importing org", org, err)
+			return err
+		}
+		if added == 0 {
 			continue
 		}
-		log.Println("Updating repos of", user)
-		_ = a.updateUsersRepos(user)
+		err = queries.InsertAudit(ctx, sqlc.InsertAuditParams{
+			Actor:     db.SystemActor,
+			Action:    db.ActionAddOrg,
+			Target:    org,
+			Before:    json.RawMessage("null"),
+			After:     after,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			log.Println("Failed auditing imported org", org, err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// refreshAll fans the users out to a pool of workers that share the rate budget,
+// handing out no more users once ctx is done. Their repos are refreshed too
+// unless repos is false.
+func (a *Aggregator) refreshAll(ctx context.Context, r *run, users map[string]struct{}, repos bool) {
+	logins := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < a.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range logins {
+				a.refresh(ctx, r, user, repos)
+			}
+		}()
+	}
+feed:
+	for user := range users {
+		select {
+		case logins <- user:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(logins)
+	wg.Wait()
+}
+
+func (a *Aggregator) refresh(ctx context.Context, r *run, user string, repos bool) {
+	log.Println("Adding/Updating", user)
+	err := a.add(ctx, r, user)
+	if errors.Is(err, ErrOptedOut) {
+		// opted out since the run started, which isn't a failure
+		log.Println("Skipping", user, err)
+		a.checkpointDone(ctx, r, user)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		a.recordError(ctx, r, user, err)
+		return
+	}
+	if !repos {
+		a.checkpointDone(ctx, r, user)
+		return
+	}
+	log.Println("Updating repos of", user)
+	if err := a.updateUsersRepos(ctx, r, user); err != nil {
+		a.recordError(ctx, r, user, err)
+		return
 	}
+	a.checkpointDone(ctx, r, user)
 }
 
 func (a *Aggregator) Running() bool {
-	return a.running
+	return a.running.Load()
 }
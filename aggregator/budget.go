@@ -0,0 +1,55 @@
+package aggregator
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+)
+
+// rateBudget is the GitHub rate limit shared by every worker of a run. When one
+// worker sees the quota exhausted the others wait for the same reset instead of
+// each burning a request to find out on their own.
+type rateBudget struct {
+	mu    sync.Mutex
+	reset time.Time
+}
+
+// wait blocks until the budget resets if a worker has reported it exhausted,
+// or until ctx is done.
+func (b *rateBudget) wait(ctx context.Context) {
+	b.mu.Lock()
+	reset := b.reset
+	b.mu.Unlock()
+	if duration := time.Until(reset); duration > 0 {
+		sleep(ctx, duration+time.Second)
+	}
+}
+
+// sleep pauses for the duration, returning early if ctx is done.
+func sleep(ctx context.Context, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// shouldTryAgain records an exhausted budget and waits for it to reset, telling
+// the caller to retry the request. It never retries once ctx is done.
+func (b *rateBudget) shouldTryAgain(ctx context.Context, r *github.Response) bool {
+	if r == nil || r.Rate.Remaining > 0 || ctx.Err() != nil {
+		return false
+	}
+	b.mu.Lock()
+	if r.Rate.Reset.Time.After(b.reset) {
+		b.reset = r.Rate.Reset.Time
+		log.Printf("I ran out of requests (%v), waiting %v\n", r.Rate.Limit, time.Until(b.reset))
+	}
+	b.mu.Unlock()
+	b.wait(ctx)
+	return ctx.Err() == nil
+}
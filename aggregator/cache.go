@@ -0,0 +1,94 @@
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// cacheStore persists the validators and bodies of GitHub responses.
+type cacheStore interface {
+	GetHTTPCache(ctx context.Context, url string) (sqlc.AggHttpCache, error)
+	UpsertHTTPCache(ctx context.Context, arg sqlc.UpsertHTTPCacheParams) error
+}
+
+// cachingTransport makes GET requests conditional on the ETag and Last-Modified
+// of the last response for the same URL. GitHub doesn't count a 304 against the
+// rate limit, so unchanged resources are served from the stored body for free.
+type cachingTransport struct {
+	base  http.RoundTripper
+	store cacheStore
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	key := req.URL.String()
+
+	cached, err := t.store.GetHTTPCache(req.Context(), key)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println("Failed reading http cache for", key, err)
+	}
+	found := err == nil
+	if found {
+		req = req.Clone(req.Context())
+		if cached.Etag != "" {
+			req.Header.Set("If-None-Match", cached.Etag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && resp.StatusCode == http.StatusNotModified {
+		// keep the live headers so the rate limit is still reported correctly
+		resp.Body.Close()
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header.Set("Content-Type", "application/json; charset=utf-8")
+		resp.Header.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+		if cached.Link != "" {
+			resp.Header.Set("Link", cached.Link)
+		}
+		resp.ContentLength = int64(len(cached.Body))
+		resp.Body = io.NopCloser(bytes.NewReader(cached.Body))
+		return resp, nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") || isDryRun(req.Context()) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = t.store.UpsertHTTPCache(req.Context(), sqlc.UpsertHTTPCacheParams{
+		Url:          key,
+		Etag:         etag,
+		LastModified: lastModified,
+		Link:         resp.Header.Get("Link"),
+		Body:         body,
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		log.Println("Failed writing http cache for", key, err)
+	}
+	return resp, nil
+}
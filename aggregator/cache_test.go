@@ -0,0 +1,73 @@
+package aggregator
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+type memoryCache map[string]sqlc.AggHttpCache
+
+func (m memoryCache) GetHTTPCache(ctx context.Context, url string) (sqlc.AggHttpCache, error) {
+	entry, ok := m[url]
+	if !ok {
+		return sqlc.AggHttpCache{}, sql.ErrNoRows
+	}
+	return entry, nil
+}
+
+func (m memoryCache) UpsertHTTPCache(ctx context.Context, arg sqlc.UpsertHTTPCacheParams) error {
+	m[arg.Url] = sqlc.AggHttpCache{
+		Url:          arg.Url,
+		Etag:         arg.Etag,
+		LastModified: arg.LastModified,
+		Link:         arg.Link,
+		Body:         arg.Body,
+		UpdatedAt:    arg.UpdatedAt,
+	}
+	return nil
+}
+
+func TestCachingTransport(t *testing.T) {
+	var hits, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Link", `<https://api.github.com/users/bob/repos?page=2>; rel="next"`)
+		_, _ = w.Write([]byte(`{"login":"bob"}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &cachingTransport{base: http.DefaultTransport, store: memoryCache{}}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL + "/users/bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Error(i, resp.StatusCode)
+		}
+		if string(body) != `{"login":"bob"}` {
+			t.Error(i, string(body))
+		}
+		if resp.Header.Get("Link") == "" {
+			t.Error(i, "expected the Link header to be preserved")
+		}
+	}
+	if hits != 2 || notModified != 1 {
+		t.Errorf("expected one full and one conditional request, got %v hits and %v not modified", hits, notModified)
+	}
+}
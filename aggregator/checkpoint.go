@@ -0,0 +1,100 @@
+package aggregator
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// resumeRun picks up the most recent run if it never finished or was aborted,
+// returning the users it still has left to refresh. It returns a nil run if
+// there is nothing to resume.
+func (a *Aggregator) resumeRun(ctx context.Context) (*run, map[string]struct{}, error) {
+	unfinished, err := a.queries.LatestUnfinishedRun(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		log.Println("Error finding unfinished run", err)
+		return nil, nil, err
+	}
+	counts, err := a.queries.CountRunUsers(ctx, unfinished.ID)
+	if err != nil {
+		log.Println("Error counting users of run", unfinished.ID, err)
+		return nil, nil, err
+	}
+	if counts.Total == 0 {
+		// stopped before its users were checkpointed, so there's nothing to carry on with
+		return nil, nil, nil
+	}
+	pending, err := a.queries.PendingRunUsers(ctx, unfinished.ID)
+	if err != nil {
+		log.Println("Error listing pending users of run", unfinished.ID, err)
+		return nil, nil, err
+	}
+	if err = a.queries.ReopenRun(ctx, unfinished.ID); err != nil {
+		log.Println("Error reopening run", unfinished.ID, err)
+		return nil, nil, err
+	}
+
+	r := &run{id: unfinished.ID, apiCallsAtStart: a.apiCalls.Load() - int64(unfinished.ApiCalls)}
+	r.usersDiscovered.Store(int32(counts.Total))
+	r.usersUpdated.Store(int32(counts.Done))
+	r.reposInserted.Store(unfinished.ReposInserted)
+	r.reposUpdated.Store(unfinished.ReposUpdated)
+	r.reposDeleted.Store(unfinished.ReposDeleted)
+
+	users := make(map[string]struct{}, len(pending))
+	for _, login := range pending {
+		users[login] = struct{}{}
+	}
+	return r, users, nil
+}
+
+// abortUnfinishedRuns marks any run left in progress as aborted. Only the
+// latest full run can still be resumed afterwards.
+func (a *Aggregator) abortUnfinishedRuns(ctx context.Context) error {
+	aborted, err := a.queries.AbortUnfinishedRuns(ctx, sql.NullTime{Time: time.Now(), Valid: true})
+	if err != nil {
+		log.Println("Error aborting unfinished runs", err)
+		return err
+	}
+	if aborted > 0 {
+		log.Println("Aborted", aborted, "unfinished runs")
+	}
+	return nil
+}
+
+// checkpointUsers saves the discovered users against the run so that a
+// restarted gather can carry on where this one left off.
+func (a *Aggregator) checkpointUsers(ctx context.Context, r *run, users map[string]struct{}) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+	queries := a.queries.WithTx(tx)
+	for login := range users {
+		err = queries.InsertRunUser(ctx, sqlc.InsertRunUserParams{RunID: r.id, Login: login})
+		if err != nil {
+			log.Println("Error checkpointing user", login, err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// checkpointDone marks the user as refreshed in this run.
+func (a *Aggregator) checkpointDone(ctx context.Context, r *run, login string) {
+	if r == nil || r.dryRun() {
+		return
+	}
+	err := a.queries.CompleteRunUser(ctx, sqlc.CompleteRunUserParams{RunID: r.id, Login: login})
+	if err != nil {
+		log.Println("Error checkpointing", login, err)
+	}
+}
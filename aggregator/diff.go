@@ -0,0 +1,333 @@
+package aggregator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+const (
+	UserAdded   = "added"
+	UserUpdated = "updated"
+	// UserRemoved is a user who would drop out of the listings for being
+	// outside the region, users are never deleted.
+	UserRemoved = "removed"
+
+	RepoInserted = "inserted"
+	RepoUpdated  = "updated"
+	RepoDeleted  = "deleted"
+)
+
+// Diff is what a dry run would have written.
+type Diff struct {
+	Users  []UserChange `json:"users"`
+	Repos  []RepoChange `json:"repos"`
+	Errors []string     `json:"errors"`
+
+	mu sync.Mutex
+}
+
+type UserChange struct {
+	Login  string        `json:"login"`
+	Change string        `json:"change"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+type RepoChange struct {
+	Owner  string        `json:"owner"`
+	Name   string        `json:"name"`
+	Change string        `json:"change"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a column that would change value, nil meaning NULL.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+	// Delta is To minus From for counts, such as stars.
+	Delta int32 `json:"delta,omitempty"`
+}
+
+func newDiff() *Diff {
+	return &Diff{Users: []UserChange{}, Repos: []RepoChange{}, Errors: []string{}}
+}
+
+func (d *Diff) addUser(change UserChange) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Users = append(d.Users, change)
+}
+
+func (d *Diff) addRepo(change RepoChange) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Repos = append(d.Repos, change)
+}
+
+func (d *Diff) addError(login string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Errors = append(d.Errors, fmt.Sprintf("%v: %v", login, err))
+}
+
+// sort orders the changes, which the workers record in whatever order they finish.
+func (d *Diff) sort() {
+	sort.Slice(d.Users, func(i, j int) bool { return d.Users[i].Login < d.Users[j].Login })
+	sort.Slice(d.Repos, func(i, j int) bool {
+		if d.Repos[i].Owner != d.Repos[j].Owner {
+			return d.Repos[i].Owner < d.Repos[j].Owner
+		}
+		return d.Repos[i].Name < d.Repos[j].Name
+	})
+	sort.Strings(d.Errors)
+}
+
+// Summary describes the diff for people, one line per change after the totals.
+func (d *Diff) Summary() string {
+	users, repos := map[string]int{}, map[string]int{}
+	for _, u := range d.Users {
+		users[u.Change]++
+	}
+	for _, r := range d.Repos {
+		repos[r.Change]++
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Users: %v added, %v updated, %v removed\n", users[UserAdded], users[UserUpdated], users[UserRemoved])
+	fmt.Fprintf(&b, "Repos: %v inserted, %v updated, %v deleted\n", repos[RepoInserted], repos[RepoUpdated], repos[RepoDeleted])
+	for _, u := range d.Users {
+		fmt.Fprintf(&b, "%v user %v%v\n", symbol(u.Change), u.Login, describeFields(u.Fields))
+	}
+	for _, r := range d.Repos {
+		fmt.Fprintf(&b, "%v repo %v/%v%v\n", symbol(r.Change), r.Owner, r.Name, describeFields(r.Fields))
+	}
+	for _, e := range d.Errors {
+		fmt.Fprintf(&b, "! %v\n", e)
+	}
+	return b.String()
+}
+
+func symbol(change string) string {
+	switch change {
+	case UserAdded, RepoInserted:
+		return "+"
+	case UserRemoved, RepoDeleted:
+		return "-"
+	}
+	return "~"
+}
+
+func describeFields(fields []FieldChange) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, f := range fields {
+		part := fmt.Sprintf("%v %v -> %v", f.Field, describeValue(f.From), describeValue(f.To))
+		if f.Delta != 0 {
+			part += fmt.Sprintf(" (%+d)", f.Delta)
+		}
+		parts = append(parts, part)
+	}
+	return ": " + strings.Join(parts, ", ")
+}
+
+func describeValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprint(v)
+}
+
+// userChange compares a stored user with what a refresh would write, returning
+// false if nothing would change.
+func userChange(existing sqlc.UserByLoginRow, update sqlc.UpdateUserParams, outside bool) (UserChange, bool) {
+	var fields []FieldChange
+	fields = compareString(fields, "name", existing.Name, update.Name)
+	fields = compareString(fields, "email", existing.Email, update.Email)
+	fields = compareString(fields, "location", existing.Location, update.Location)
+	fields = compareBool(fields, "hireable", existing.Hireable, update.Hireable)
+	fields = compareString(fields, "blog", existing.Blog, update.Blog)
+	fields = compareString(fields, "bio", existing.Bio, update.Bio)
+	fields = compareString(fields, "company", sql.NullString{String: existing.Company, Valid: true}, sql.NullString{String: update.Company, Valid: true})
+	fields = compareString(fields, "type", existing.Type, update.Type)
+	fields = compareString(fields, "avatar_url", existing.AvatarUrl, update.AvatarUrl)
+	fields = compareInt(fields, "followers", existing.Followers, update.Followers)
+	fields = compareInt(fields, "following", existing.Following, update.Following)
+	fields = compareInt(fields, "public_repos", existing.PublicRepos, update.PublicRepos)
+	fields = compareInt(fields, "public_gists", existing.PublicGists, update.PublicGists)
+	if existing.OutsideRegion != outside {
+		fields = append(fields, FieldChange{Field: "outside_region", From: existing.OutsideRegion, To: outside})
+	}
+
+	change := UserChange{Login: update.Login, Change: UserUpdated, Fields: fields}
+	if outside && !existing.OutsideRegion {
+		change.Change = UserRemoved
+	}
+	return change, len(fields) > 0
+}
+
+// repoChange compares a stored repo with what a refresh would write, returning
+// false if nothing would change.
+func repoChange(existing sqlc.ReposByOwnerRow, update sqlc.InsertRepoParams) (RepoChange, bool) {
+	var fields []FieldChange
+	fields = compareString(fields, "description", existing.Description, update.Description)
+	fields = compareString(fields, "language", existing.Language, update.Language)
+	fields = compareString(fields, "homepage", existing.Homepage, update.Homepage)
+	fields = compareInt(fields, "stargazers_count", existing.StargazersCount, update.StargazersCount)
+	fields = compareInt(fields, "forks_count", existing.ForksCount, update.ForksCount)
+	fields = compareInt(fields, "watchers_count", existing.WatchersCount, update.WatchersCount)
+	fields = compareInt(fields, "open_issues_count", existing.OpenIssuesCount, update.OpenIssuesCount)
+	fields = compareBool(fields, "fork", existing.Fork, update.Fork)
+	fields = compareString(fields, "default_branch", existing.DefaultBranch, update.DefaultBranch)
+	return RepoChange{Owner: update.Owner, Name: update.Name, Change: RepoUpdated, Fields: fields}, len(fields) > 0
+}
+
+func compareString(fields []FieldChange, field string, from, to sql.NullString) []FieldChange {
+	if from == to {
+		return fields
+	}
+	change := FieldChange{Field: field}
+	if from.Valid {
+		change.From = from.String
+	}
+	if to.Valid {
+		change.To = to.String
+	}
+	return append(fields, change)
+}
+
+func compareInt(fields []FieldChange, field string, from, to sql.NullInt32) []FieldChange {
+	if from == to {
+		return fields
+	}
+	change := FieldChange{Field: field, Delta: to.Int32 - from.Int32}
+	if from.Valid {
+		change.From = from.Int32
+	}
+	if to.Valid {
+		change.To = to.Int32
+	}
+	return append(fields, change)
+}
+
+func compareBool(fields []FieldChange, field string, from, to sql.NullBool) []FieldChange {
+	if from == to {
+		return fields
+	}
+	change := FieldChange{Field: field}
+	if from.Valid {
+		change.From = from.Bool
+	}
+	if to.Valid {
+		change.To = to.Bool
+	}
+	return append(fields, change)
+}
+
+// diffUser records how refreshing the user would change them.
+func (a *Aggregator) diffUser(ctx context.Context, r *run, update sqlc.UpdateUserParams, outside bool) error {
+	existing, err := a.queries.UserByLogin(ctx, update.Login)
+	if err == sql.ErrNoRows {
+		change := UserChange{Login: update.Login, Change: UserAdded}
+		if outside {
+			change.Fields = []FieldChange{{Field: "outside_region", To: true}}
+		}
+		r.diff.addUser(change)
+		r.userUpdated()
+		return nil
+	}
+	if err != nil {
+		log.Println("Error querying user", update.Login, err)
+		return err
+	}
+	if change, changed := userChange(existing, update, outside); changed {
+		r.diff.addUser(change)
+	}
+	r.userUpdated()
+	return nil
+}
+
+// storedRepos returns the user's repos as they are in the database, by name.
+func (a *Aggregator) storedRepos(ctx context.Context, owner string) (map[string]sqlc.ReposByOwnerRow, error) {
+	repos, err := a.queries.ReposByOwner(ctx, owner)
+	if err != nil {
+		log.Println("Error querying repos of", owner, err)
+		return nil, err
+	}
+	stored := make(map[string]sqlc.ReposByOwnerRow, len(repos))
+	for _, repo := range repos {
+		stored[repo.Name] = repo
+	}
+	return stored, nil
+}
+
+// diffRepo records how refreshing the repo would change it, taking it out of
+// stored so the repos left over are the ones that would be deleted.
+func (r *run) diffRepo(stored map[string]sqlc.ReposByOwnerRow, update sqlc.InsertRepoParams) {
+	existing, found := stored[update.Name]
+	if !found {
+		r.diff.addRepo(RepoChange{Owner: update.Owner, Name: update.Name, Change: RepoInserted})
+		r.repoInserted()
+		return
+	}
+	delete(stored, update.Name)
+	if change, changed := repoChange(existing, update); changed {
+		r.diff.addRepo(change)
+	}
+	r.repoUpdated()
+}
+
+// diffDeletedRepos records the repos DeleteReposByOwnerBefore would remove,
+// those GitHub no longer listed.
+func (r *run) diffDeletedRepos(stored map[string]sqlc.ReposByOwnerRow) {
+	for _, repo := range stored {
+		r.diff.addRepo(RepoChange{Owner: repo.Owner, Name: repo.Name, Change: RepoDeleted})
+	}
+	r.reposRemoved(int64(len(stored)))
+}
+
+type dryRunKey struct{}
+
+// withDryRun marks requests made with the context as part of a dry run, so the
+// HTTP cache doesn't store their responses either.
+func withDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+func isDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// DryRun does the GitHub reads of a run with the same options and returns what
+// it would change without writing anything. A full dry run always discovers
+// users afresh rather than looking at an unfinished run.
+func (a *Aggregator) DryRun(ctx context.Context, opts RunOptions) (*Diff, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeFull
+	}
+	ctx = withDryRun(ctx)
+	r := &run{diff: newDiff()}
+	users, err := a.targets(ctx, r, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.usersDiscovered.Store(int32(len(users)))
+	a.refreshAll(ctx, r, users, opts.Mode != ModeDiscover)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.diff.sort()
+	return r.diff, nil
+}
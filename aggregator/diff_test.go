@@ -0,0 +1,83 @@
+package aggregator
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+func TestRepoChange(t *testing.T) {
+	existing := sqlc.ReposByOwnerRow{
+		Owner:           "bob",
+		Name:            "tool",
+		Description:     sql.NullString{String: "A tool", Valid: true},
+		StargazersCount: sql.NullInt32{Int32: 5, Valid: true},
+		ForksCount:      sql.NullInt32{Int32: 1, Valid: true},
+	}
+	update := sqlc.InsertRepoParams{
+		Owner:           "bob",
+		Name:            "tool",
+		Description:     existing.Description,
+		StargazersCount: sql.NullInt32{Int32: 9, Valid: true},
+		ForksCount:      existing.ForksCount,
+		Language:        sql.NullString{String: "Go", Valid: true},
+	}
+
+	change, changed := repoChange(existing, update)
+	if !changed {
+		t.Fatal("expected a change")
+	}
+	if len(change.Fields) != 2 {
+		t.Fatalf("expected language and stars to change, got %+v", change.Fields)
+	}
+	if f := change.Fields[0]; f.Field != "language" || f.From != nil || f.To != "Go" {
+		t.Errorf("unexpected %+v", f)
+	}
+	if f := change.Fields[1]; f.Field != "stargazers_count" || f.Delta != 4 {
+		t.Errorf("unexpected %+v", f)
+	}
+
+	if _, changed = repoChange(existing, sqlc.InsertRepoParams{
+		Owner:           "bob",
+		Name:            "tool",
+		Description:     existing.Description,
+		StargazersCount: existing.StargazersCount,
+		ForksCount:      existing.ForksCount,
+	}); changed {
+		t.Error("expected no change")
+	}
+}
+
+func TestUserChangeRemoved(t *testing.T) {
+	existing := sqlc.UserByLoginRow{Login: "bob", Location: sql.NullString{String: "STL", Valid: true}}
+	update := sqlc.UpdateUserParams{Login: "bob", Location: sql.NullString{String: "Chicago", Valid: true}}
+
+	change, changed := userChange(existing, update, true)
+	if !changed || change.Change != UserRemoved {
+		t.Fatalf("expected bob to be removed, got %+v", change)
+	}
+	if len(change.Fields) != 2 || change.Fields[1].Field != "outside_region" {
+		t.Errorf("unexpected %+v", change.Fields)
+	}
+}
+
+func TestDiffSummary(t *testing.T) {
+	d := newDiff()
+	d.addRepo(RepoChange{Owner: "bob", Name: "tool", Change: RepoUpdated, Fields: []FieldChange{
+		{Field: "stargazers_count", From: int32(5), To: int32(9), Delta: 4},
+	}})
+	d.addRepo(RepoChange{Owner: "bob", Name: "old", Change: RepoDeleted})
+	d.addUser(UserChange{Login: "alice", Change: UserAdded})
+	d.sort()
+
+	expected := `Users: 1 added, 0 updated, 0 removed
+Repos: 0 inserted, 1 updated, 1 deleted
++ user alice
+- repo bob/old
+~ repo bob/tool: stargazers_count 5 -> 9 (+4)
+`
+	if got := d.Summary(); got != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, got)
+	}
+}
@@ -0,0 +1,112 @@
+package aggregator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+const (
+	// runLease names the lease held by whichever process is running the aggregator.
+	runLease = "run"
+	// leaseTTL is how long a lease outlives its last heartbeat, after which a
+	// crashed process's lease is taken over by the next run.
+	leaseTTL          = 2 * time.Minute
+	heartbeatInterval = leaseTTL / 4
+)
+
+// leaseHolder identifies this process in the lease table.
+func leaseHolder() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%v:%v:%v", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// acquireLease takes the run lease so no other process runs at the same time,
+// returning ErrRunInProgress if one does. The lease is kept alive in the
+// background until release is called, and the returned context is cancelled
+// if the lease is lost.
+func (a *Aggregator) acquireLease(ctx context.Context) (context.Context, func(), error) {
+	acquired, err := a.queries.AcquireLease(ctx, sqlc.AcquireLeaseParams{
+		Name:       runLease,
+		Holder:     a.holder,
+		TtlSeconds: int32(leaseTTL / time.Second),
+	})
+	if err != nil {
+		log.Println("Error acquiring run lease", err)
+		return nil, nil, err
+	}
+	if acquired == 0 {
+		return nil, nil, ErrRunInProgress
+	}
+
+	leased, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.heartbeat(leased, cancel)
+	}()
+	release := func() {
+		cancel()
+		<-done
+		err := a.queries.ReleaseLease(context.WithoutCancel(ctx), sqlc.ReleaseLeaseParams{Name: runLease, Holder: a.holder})
+		if err != nil {
+			log.Println("Error releasing run lease", err)
+		}
+	}
+	return leased, release, nil
+}
+
+// leaseAcquiredAt reports when the process running the aggregator took the run
+// lease, or the zero time if no process holds it.
+func (a *Aggregator) leaseAcquiredAt(ctx context.Context) (time.Time, error) {
+	lease, err := a.queries.GetLease(ctx, runLease)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		log.Println("Error reading run lease", err)
+		return time.Time{}, err
+	}
+	return lease.AcquiredAt, nil
+}
+
+// heartbeat renews the lease until ctx is done. If the lease was taken over, or
+// couldn't be renewed before it expired, the run is stopped with cancel.
+func (a *Aggregator) heartbeat(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		renewed, err := a.queries.RenewLease(ctx, sqlc.RenewLeaseParams{
+			TtlSeconds: int32(leaseTTL / time.Second),
+			Name:       runLease,
+			Holder:     a.holder,
+		})
+		if err == nil && renewed == 0 {
+			log.Println("Lost the run lease to another process, stopping")
+			cancel()
+			return
+		}
+		if err != nil {
+			log.Println("Error renewing run lease", err)
+			if time.Since(renewedAt) > leaseTTL {
+				log.Println("Run lease expired, stopping")
+				cancel()
+				return
+			}
+			continue
+		}
+		renewedAt = time.Now()
+	}
+}
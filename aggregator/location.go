@@ -0,0 +1,125 @@
+package aggregator
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/google/go-github/v52/github"
+	"github.com/jakecoffman/stldevs/config"
+)
+
+type locationMatch int
+
+const (
+	// locationUnknown is an empty location, which can't be placed anywhere.
+	locationUnknown locationMatch = iota
+	locationInRegion
+	locationOutside
+)
+
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// normalizeLocation folds a free text location into lowercase words without
+// accents or punctuation, spelling "Saint" the way it's usually abbreviated.
+func normalizeLocation(location string) []string {
+	location = accents.Replace(strings.ToLower(location))
+	words := strings.FieldsFunc(location, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		if word == "saint" {
+			words[i] = "st"
+		}
+	}
+	return words
+}
+
+// locationClassifier decides whether a GitHub profile location is in the region.
+// GitHub's location search matches loosely, so the aggregator re-checks every
+// location it stores.
+type locationClassifier struct {
+	aliases [][]string
+	exclude [][]string
+}
+
+func newLocationClassifier(region config.Region) *locationClassifier {
+	c := &locationClassifier{}
+	for _, alias := range region.Locations {
+		c.aliases = append(c.aliases, normalizeLocation(alias))
+	}
+	for _, exclude := range region.Exclude {
+		c.exclude = append(c.exclude, normalizeLocation(exclude))
+	}
+	return c
+}
+
+// classify matches the location against the region. An exclusion outweighs
+// an alias, so "St. Louis Park, MN" is outside even though it mentions St. Louis.
+func (c *locationClassifier) classify(location string) locationMatch {
+	words := normalizeLocation(location)
+	if len(words) == 0 {
+		return locationUnknown
+	}
+	for _, exclude := range c.exclude {
+		if containsWords(words, exclude) {
+			return locationOutside
+		}
+	}
+	for _, alias := range c.aliases {
+		if containsWords(words, alias) {
+			return locationInRegion
+		}
+	}
+	return locationOutside
+}
+
+// containsWords reports whether phrase appears in words as whole words.
+func containsWords(words, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j := range phrase {
+			if words[i+j] != phrase[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// outsideRegion decides whether a user drops out of the listings. An admin
+// override always wins. Users who opted in stay regardless of location, and
+// organizations are only added from the org list so their location isn't
+// checked either. Everyone else needs a location in the region.
+func (a *Aggregator) outsideRegion(ctx context.Context, u *github.User) (bool, error) {
+	override, err := a.queries.GetLocationOverride(ctx, u.GetLogin())
+	if err == nil {
+		return !override.Include, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+	optedIn, err := a.queries.IsOptedIn(ctx, u.GetLogin())
+	if err != nil {
+		return false, err
+	}
+	if optedIn || u.GetType() == "Organization" {
+		return false, nil
+	}
+	return a.locations.classify(u.GetLocation()) != locationInRegion, nil
+}
@@ -0,0 +1,31 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/jakecoffman/stldevs/config"
+)
+
+func TestClassifyLocation(t *testing.T) {
+	region := config.DefaultRegion
+	region.Locations = append(region.Locations, "Kirkwood")
+	classifier := newLocationClassifier(region)
+
+	for location, expected := range map[string]locationMatch{
+		"St. Louis, MO":        locationInRegion,
+		"STL":                  locationInRegion,
+		"saint louis":          locationInRegion,
+		"East St. Louis, IL":   locationInRegion,
+		"Kirkwood, Missouri":   locationInRegion,
+		"St. Louis Park, MN":   locationOutside,
+		"Saint-Louis, Sénégal": locationOutside,
+		"Chicago":              locationOutside,
+		"Stlouisville":         locationOutside,
+		"":                     locationUnknown,
+		"  ,  ":                locationUnknown,
+	} {
+		if got := classifier.classify(location); got != expected {
+			t.Errorf("%q: expected %v, got %v", location, expected, got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package aggregator
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// includeQueueSize bounds how many opted in users wait to be refreshed, any
+// more are left to the next full run, which includes every opted in user.
+const includeQueueSize = 64
+
+// includeQueue holds the opted in users waiting for their own run, each login
+// at most once.
+type includeQueue struct {
+	mu     sync.Mutex
+	queued map[string]struct{}
+	logins chan string
+}
+
+func newIncludeQueue() *includeQueue {
+	return &includeQueue{
+		queued: map[string]struct{}{},
+		logins: make(chan string, includeQueueSize),
+	}
+}
+
+// push queues the login, returning false if it was already queued or the
+// queue is full.
+func (q *includeQueue) push(login string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := strings.ToLower(login)
+	if _, ok := q.queued[key]; ok {
+		return false
+	}
+	select {
+	case q.logins <- login:
+		q.queued[key] = struct{}{}
+		return true
+	default:
+		return false
+	}
+}
+
+// pop waits for the next queued login, which can be queued again from then on.
+// It returns false if ctx is done first.
+func (q *includeQueue) pop(ctx context.Context) (string, bool) {
+	var login string
+	select {
+	case login = <-q.logins:
+	case <-ctx.Done():
+		return "", false
+	}
+	q.mu.Lock()
+	delete(q.queued, strings.ToLower(login))
+	q.mu.Unlock()
+	return login, true
+}
+
+// Include records that the user asked to be listed and queues a run refreshing
+// them, see refreshIncluded. Opted in users are part of every run from then on
+// and are kept regardless of their location. The queued runs take their turn
+// like any other, if one is already in progress the user waits for the next
+// full run.
+// It returns ErrOptedOut if the user opted out, which they have to undo first.
+func (a *Aggregator) Include(ctx context.Context, login string) error {
+	if err := a.checkOptOut(ctx, login); err != nil {
+		return err
+	}
+	err := a.queries.InsertOptIn(ctx, sqlc.InsertOptInParams{Login: login, RequestedAt: time.Now()})
+	if err != nil {
+		log.Println("Failed opting in", login, err)
+		return err
+	}
+	if !a.includes.push(login) {
+		log.Println("Not queueing", login, "who is left to the next full run")
+	}
+	return nil
+}
+
+// refreshIncluded runs the queued opted in users one at a time until ctx is
+// done, which aborts the run in progress. Users still queued then are left to
+// the next full run.
+func (a *Aggregator) refreshIncluded(ctx context.Context) {
+	for {
+		login, ok := a.includes.pop(ctx)
+		if !ok {
+			return
+		}
+		a.Run(ctx, RunOptions{Mode: ModeUser, Login: login})
+	}
+}
@@ -0,0 +1,39 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestIncludeQueue(t *testing.T) {
+	q := newIncludeQueue()
+	if !q.push("bob") {
+		t.Fatal("expected bob to be queued")
+	}
+	if q.push("Bob") {
+		t.Error("expected bob to be queued once")
+	}
+	if login, ok := q.pop(context.Background()); !ok || login != "bob" {
+		t.Fatal(login)
+	}
+	if !q.push("bob") {
+		t.Error("expected bob to be queued again once taken")
+	}
+
+	for i := 1; i < includeQueueSize; i++ {
+		if !q.push(fmt.Sprint("user", i)) {
+			t.Fatal("expected room for", i)
+		}
+	}
+	if q.push("alice") {
+		t.Error("expected the queue to be full")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	empty := newIncludeQueue()
+	if login, ok := empty.pop(ctx); ok {
+		t.Error("expected pop to stop when the context is done, got", login)
+	}
+}
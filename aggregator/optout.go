@@ -0,0 +1,47 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+)
+
+// ErrOptedOut is returned when asked to gather a user who opted out of being listed.
+var ErrOptedOut = errors.New("user opted out of being listed")
+
+// withoutOptOuts removes the users who opted out from the ones a run refreshes,
+// wherever they were found. Logins are compared ignoring case like GitHub does.
+func (a *Aggregator) withoutOptOuts(ctx context.Context, users map[string]struct{}) (map[string]struct{}, error) {
+	optOuts, err := a.queries.OptOutLogins(ctx)
+	if err != nil {
+		log.Println("Failed listing opted out users", err)
+		return nil, err
+	}
+	if len(optOuts) == 0 {
+		return users, nil
+	}
+	optedOut := make(map[string]struct{}, len(optOuts))
+	for _, login := range optOuts {
+		optedOut[strings.ToLower(login)] = struct{}{}
+	}
+	for login := range users {
+		if _, found := optedOut[strings.ToLower(login)]; found {
+			delete(users, login)
+		}
+	}
+	return users, nil
+}
+
+// checkOptOut returns ErrOptedOut if the user opted out.
+func (a *Aggregator) checkOptOut(ctx context.Context, login string) error {
+	optedOut, err := a.queries.IsOptedOut(ctx, login)
+	if err != nil {
+		log.Println("Failed checking opt out of", login, err)
+		return err
+	}
+	if optedOut {
+		return ErrOptedOut
+	}
+	return nil
+}
@@ -5,20 +5,29 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v52/github"
+	"github.com/jakecoffman/stldevs/config"
 	"github.com/jakecoffman/stldevs/db/sqlc"
 )
 
-func (a *Aggregator) updateUsersRepos(user string) error {
-	ctx := context.Background()
+func (a *Aggregator) updateUsersRepos(ctx context.Context, r *run, user string) error {
 	now := time.Now()
+	var stored map[string]sqlc.ReposByOwnerRow
+	if r.dryRun() {
+		var err error
+		if stored, err = a.storedRepos(ctx, user); err != nil {
+			return err
+		}
+	}
 
 	opts := &github.RepositoryListOptions{Type: "owner", Sort: "updated", Direction: "desc", ListOptions: github.ListOptions{PerPage: 100}}
 	for {
+		a.budget.wait(ctx)
 		result, resp, err := a.client.Repositories.List(ctx, user, opts)
-		if shouldTryAgain(resp) {
+		if a.budget.shouldTryAgain(ctx, resp) {
 			continue
 		}
 		if err != nil {
@@ -31,6 +40,10 @@ func (a *Aggregator) updateUsersRepos(user string) error {
 				log.Println(err)
 				continue
 			}
+			if r.dryRun() {
+				r.diffRepo(stored, params)
+				continue
+			}
 			updated, err := a.queries.UpdateRepo(ctx, toUpdateRepoParams(params))
 			if err != nil {
 				log.Println(err)
@@ -41,6 +54,12 @@ func (a *Aggregator) updateUsersRepos(user string) error {
 					log.Println("Error executing replace into agg_repo", err)
 					return err
 				}
+				r.repoInserted()
+			} else {
+				r.repoUpdated()
+			}
+			if err := a.snapshotRepo(ctx, r, params); err != nil {
+				return err
 			}
 		}
 		if resp.NextPage == 0 {
@@ -48,6 +67,10 @@ func (a *Aggregator) updateUsersRepos(user string) error {
 		}
 		opts.Page = resp.NextPage
 	}
+	if r.dryRun() {
+		r.diffDeletedRepos(stored)
+		return nil
+	}
 	deleted, err := a.queries.DeleteReposByOwnerBefore(ctx, sqlc.DeleteReposByOwnerBeforeParams{
 		Owner:       user,
 		RefreshedAt: sql.NullTime{Time: now, Valid: true},
@@ -56,52 +79,82 @@ func (a *Aggregator) updateUsersRepos(user string) error {
 		log.Printf("Error deleting out of date repos for user %v: %v", user, err)
 		return err
 	}
+	r.reposRemoved(deleted)
 	log.Printf("Deleted %v repos that user %v was missing", deleted, user)
 	return nil
 }
 
-func FindInStl(client *github.Client, typ string) (map[string]struct{}, error) {
+// locationsPerSearch is how many location qualifiers go into one search query,
+// larger regions are searched in several passes.
+const locationsPerSearch = 4
+
+// locationQueries groups the region's locations into search qualifiers, each
+// excluding the places that only look like they're in the region.
+func locationQueries(region config.Region) []string {
+	var exclude []string
+	for _, location := range region.Exclude {
+		exclude = append(exclude, fmt.Sprintf(`-location:"%v"`, location))
+	}
+	var queries []string
+	for i := 0; i < len(region.Locations); i += locationsPerSearch {
+		var terms []string
+		for _, location := range region.Locations[i:min(i+locationsPerSearch, len(region.Locations))] {
+			terms = append(terms, fmt.Sprintf(`location:"%v"`, location))
+		}
+		queries = append(queries, strings.Join(append(terms, exclude...), " "))
+	}
+	return queries
+}
+
+// FindInStl searches GitHub for accounts of the given type located in the region.
+func FindInStl(ctx context.Context, client *github.Client, region config.Region, typ string) (map[string]struct{}, error) {
 	users := map[string]struct{}{}
+	// the search API has its own quota, separate from the one the workers share
+	budget := &rateBudget{}
 
-	// since github limits to 1000 results, break the search up with created
-	for _, date := range []string{
-		"created:<2013-01-01",
-		"created:2013-01-01..2014-01-01",
-		"created:2014-01-01..2015-01-01",
-		"created:2015-01-01..2016-01-01",
-		"created:2016-01-01..2017-01-01",
-		"created:2017-01-01..2018-01-01",
-		"created:2018-01-01..2019-01-01",
-		"created:2019-01-01..2020-01-01",
-		"created:2020-01-01..2021-01-01",
-		"created:2021-01-01..2022-01-01",
-		"created:2022-01-01..2023-01-01",
-		"created:2023-01-01..2024-01-01",
-		"created:2024-01-01..2025-01-01",
-		"created:>2025-01-01",
-	} {
-		const locations = `location:"St. Louis" location:"STL" location:"St Louis" location:"Saint Louis"`
-		searchString := fmt.Sprintf(`%v %v repos:>1 type:"%v"`, locations, date, typ)
+	for _, locations := range locationQueries(region) {
+		if err := searchWindows(ctx, client, budget, locations, typ, users); err != nil {
+			return users, err
+		}
+	}
+	fmt.Printf("total of type %v found in %v: %v\n", typ, region.Name, len(users))
+	return users, nil
+}
+
+func searchWindows(ctx context.Context, client *github.Client, budget *rateBudget, locations, typ string, users map[string]struct{}) error {
+	// since github limits to 1000 results, break the search up with created,
+	// splitting any window that still hits the limit into smaller ones
+	windows := yearWindows(time.Now())
+	for len(windows) > 0 {
+		w := windows[0]
+		windows = windows[1:]
+
+		searchString := fmt.Sprintf(`%v %v repos:>1 type:"%v"`, locations, w.qualifier(), typ)
 		opts := &github.SearchOptions{
 			ListOptions: github.ListOptions{Page: 1, PerPage: 100},
 			Sort:        "repositories",
 		}
 		for {
-			time.Sleep(2 * time.Second)
-			result, resultResp, err := client.Search.Users(context.Background(), searchString, opts)
-			if shouldTryAgain(resultResp) {
+			sleep(ctx, 2*time.Second)
+			result, resultResp, err := client.Search.Users(ctx, searchString, opts)
+			if budget.shouldTryAgain(ctx, resultResp) {
 				continue
 			}
 			if err != nil {
 				log.Println(err)
-				return users, err
+				return err
+			}
+			if opts.Page == 1 && result.GetTotal() > searchCap {
+				if smaller := w.split(); smaller != nil {
+					log.Printf("%v has %v results, splitting it into %v windows", w.qualifier(), result.GetTotal(), len(smaller))
+					windows = append(smaller, windows...)
+					break
+				}
+				log.Println("Warning: hit the limit on user search for", w.qualifier())
 			}
 			for _, user := range result.Users {
 				users[*user.Login] = struct{}{}
 			}
-			if resultResp.NextPage == 10 {
-				log.Println("Warning: hit the limit on user search for date", date)
-			}
 			if resultResp.NextPage == 0 {
 				break
 			}
@@ -109,14 +162,23 @@ func FindInStl(client *github.Client, typ string) (map[string]struct{}, error) {
 			opts.ListOptions.Page = resultResp.NextPage
 		}
 	}
-	fmt.Printf("total of type %v found: %v\n", typ, len(users))
-	return users, nil
+	return nil
 }
 
-func (a *Aggregator) Add(user string) error {
+// Add fetches a user from GitHub and inserts or updates them. It returns
+// ErrOptedOut for a user who opted out.
+func (a *Aggregator) Add(ctx context.Context, user string) error {
+	return a.add(ctx, nil, user)
+}
+
+func (a *Aggregator) add(ctx context.Context, r *run, user string) error {
+	if err := a.checkOptOut(ctx, user); err != nil {
+		return err
+	}
 start:
-	u, resp, err := a.client.Users.Get(context.Background(), user)
-	if shouldTryAgain(resp) {
+	a.budget.wait(ctx)
+	u, resp, err := a.client.Users.Get(ctx, user)
+	if a.budget.shouldTryAgain(ctx, resp) {
 		goto start
 	}
 	if err != nil || u == nil {
@@ -128,36 +190,38 @@ start:
 		log.Println(err)
 		return err
 	}
-	updated, err := a.queries.UpdateUser(context.Background(), updateParams)
+	outside, err := a.outsideRegion(ctx, u)
+	if err != nil {
+		log.Println("Failed classifying location of", user, err)
+		return err
+	}
+	if outside {
+		log.Printf("%v is outside of %v: %q", user, a.region.Name, u.GetLocation())
+	}
+	if r.dryRun() {
+		return a.diffUser(ctx, r, updateParams, outside)
+	}
+	updated, err := a.queries.UpdateUser(ctx, updateParams)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 	if updated == 0 {
-		if err := a.queries.InsertUser(context.Background(), insertParams); err != nil {
+		if err := a.queries.InsertUser(ctx, insertParams); err != nil {
 			log.Println(err)
 			return err
 		}
 	}
-	return nil
-}
-
-func (a *Aggregator) insertRunLog() error {
-	if err := a.queries.InsertRunLog(context.Background(), time.Now()); err != nil {
-		log.Println("Error executing insert", err)
+	err = a.queries.SetUserOutsideRegion(ctx, sqlc.SetUserOutsideRegionParams{Login: updateParams.Login, OutsideRegion: outside})
+	if err != nil {
+		log.Println(err)
 		return err
 	}
-	return nil
-}
-
-func shouldTryAgain(r *github.Response) bool {
-	if r.Rate.Remaining <= 0 {
-		duration := time.Until(r.Rate.Reset.Time)
-		fmt.Printf("I ran out of requests (%v), waiting %v\n", r.Rate.Limit, duration)
-		time.Sleep(duration + time.Second)
-		return true
+	if err = a.snapshotUser(ctx, r, updateParams); err != nil {
+		return err
 	}
-	return false
+	r.userUpdated()
+	return nil
 }
 
 func buildRepoParams(repo *github.Repository, refreshedAt time.Time) (sqlc.InsertRepoParams, error) {
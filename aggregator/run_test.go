@@ -0,0 +1,23 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/jakecoffman/stldevs/config"
+)
+
+func TestLocationQueries(t *testing.T) {
+	queries := locationQueries(config.Region{
+		Locations: []string{"St. Louis", "STL", "St Louis", "Saint Louis", "Kirkwood"},
+		Exclude:   []string{"St. Louis Park"},
+	})
+	if len(queries) != 2 {
+		t.Fatalf("expected 2 queries, got %v", queries)
+	}
+	if queries[0] != `location:"St. Louis" location:"STL" location:"St Louis" location:"Saint Louis" -location:"St. Louis Park"` {
+		t.Error(queries[0])
+	}
+	if queries[1] != `location:"Kirkwood" -location:"St. Louis Park"` {
+		t.Error(queries[1])
+	}
+}
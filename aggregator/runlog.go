@@ -0,0 +1,139 @@
+package aggregator
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusAborted   = "aborted"
+)
+
+// Modes choose which users a run refreshes.
+const (
+	// ModeFull discovers the users in the region and refreshes them with their repos.
+	ModeFull = "full"
+	// ModeUser refreshes a single login.
+	ModeUser = "user"
+	// ModeOrgs refreshes the organizations in the registry.
+	ModeOrgs = "orgs"
+	// ModeStale refreshes the users that haven't been refreshed for a while.
+	ModeStale = "stale"
+	// ModeDiscover discovers the users in the region and refreshes their
+	// profiles, leaving their repos alone.
+	ModeDiscover = "discover"
+)
+
+// run is the agg_run row of a run in progress and the statistics the workers
+// report into it. A nil *run is valid and records nothing, which is what Add
+// uses when it's called outside of a run. A dry run has no row and collects
+// what it would have written in diff instead.
+type run struct {
+	id              int64
+	diff            *Diff
+	apiCallsAtStart int64
+	usersDiscovered atomic.Int32
+	usersUpdated    atomic.Int32
+	reposInserted   atomic.Int32
+	reposUpdated    atomic.Int32
+	reposDeleted    atomic.Int32
+}
+
+func (r *run) dryRun() bool {
+	return r != nil && r.diff != nil
+}
+
+func (r *run) userUpdated() {
+	if r != nil {
+		r.usersUpdated.Add(1)
+	}
+}
+
+func (r *run) repoInserted() {
+	if r != nil {
+		r.reposInserted.Add(1)
+	}
+}
+
+func (r *run) repoUpdated() {
+	if r != nil {
+		r.reposUpdated.Add(1)
+	}
+}
+
+func (r *run) reposRemoved(n int64) {
+	if r != nil {
+		r.reposDeleted.Add(int32(n))
+	}
+}
+
+func (a *Aggregator) startRun(ctx context.Context, mode string) (*run, error) {
+	id, err := a.queries.InsertRun(ctx, sqlc.InsertRunParams{StartedAt: time.Now(), Mode: mode})
+	if err != nil {
+		log.Println("Error inserting run", err)
+		return nil, err
+	}
+	return &run{id: id, apiCallsAtStart: a.apiCalls.Load()}, nil
+}
+
+// finishRun records the outcome of the run. It's recorded even when ctx has
+// been cancelled, since that is how an aborted run gets its status.
+func (a *Aggregator) finishRun(ctx context.Context, r *run, status string) {
+	err := a.queries.FinishRun(context.WithoutCancel(ctx), sqlc.FinishRunParams{
+		ID:              r.id,
+		FinishedAt:      sql.NullTime{Time: time.Now(), Valid: true},
+		Status:          status,
+		UsersDiscovered: r.usersDiscovered.Load(),
+		UsersUpdated:    r.usersUpdated.Load(),
+		ReposInserted:   r.reposInserted.Load(),
+		ReposUpdated:    r.reposUpdated.Load(),
+		ReposDeleted:    r.reposDeleted.Load(),
+		ApiCalls:        int32(a.apiCalls.Load() - r.apiCallsAtStart),
+	})
+	if err != nil {
+		log.Println("Error finishing run", r.id, err)
+		return
+	}
+	log.Printf("Run %v %v", r.id, status)
+}
+
+// recordError keeps an error against the run so it shows up in its history.
+// Errors once ctx is done are caused by the cancellation and aren't kept.
+func (a *Aggregator) recordError(ctx context.Context, r *run, login string, err error) {
+	if r == nil || err == nil || ctx.Err() != nil {
+		return
+	}
+	if r.dryRun() {
+		r.diff.addError(login, err)
+		return
+	}
+	insertErr := a.queries.InsertRunError(ctx, sqlc.InsertRunErrorParams{
+		RunID:     r.id,
+		Login:     login,
+		Message:   err.Error(),
+		CreatedAt: time.Now(),
+	})
+	if insertErr != nil {
+		log.Println("Error recording run error", insertErr)
+	}
+}
+
+// countingTransport counts the requests that actually go out to GitHub.
+type countingTransport struct {
+	base  http.RoundTripper
+	calls *atomic.Int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,168 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ErrRunInProgress is returned when a run is requested while one is going,
+// either in this process or in another instance holding the run lease.
+var ErrRunInProgress = errors.New("a run is already in progress")
+
+// parseSchedule parses a standard five field cron expression such as
+// "0 3 * * *", a descriptor such as @daily, or @every followed by a duration
+// such as "@every 6h". Times are in the server's time zone unless the spec
+// starts with CRON_TZ=. An empty spec is no schedule, which is nil.
+func parseSchedule(spec string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok && every.Delay < time.Minute {
+		return nil, fmt.Errorf("invalid schedule %q: runs must be at least a minute apart", spec)
+	}
+	return schedule, nil
+}
+
+// SchedulerStatus describes the run in progress, if any. It may have been
+// started by the scheduler, by an opt in, or by another instance.
+type SchedulerStatus struct {
+	Running   bool      `json:"running"`
+	StartedAt time.Time `json:"started_at"`
+	Schedule  string    `json:"schedule"`
+}
+
+// Scheduler runs the aggregator inside the web server, on a schedule and
+// whenever an admin asks for a run.
+type Scheduler struct {
+	agg      *Aggregator
+	spec     string
+	schedule cron.Schedule
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	startedAt time.Time
+	// runs are the runs in progress, so Start can wait for them to be recorded.
+	runs sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler running agg on the spec, see parseSchedule.
+// With an empty spec it only runs when triggered.
+func NewScheduler(agg *Aggregator, spec string) (*Scheduler, error) {
+	schedule, err := parseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{agg: agg, spec: spec, schedule: schedule}, nil
+}
+
+// Start triggers a run each time the schedule comes around, and runs the opted
+// in users as they are queued, until ctx is done. Then it cancels the runs in
+// progress and waits for them to be recorded as aborted.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.agg.refreshIncluded(ctx)
+	}()
+	if s.schedule != nil {
+		log.Println("Scheduling runs", s.spec)
+		timer := time.NewTimer(time.Until(s.schedule.Next(time.Now())))
+		defer timer.Stop()
+	loop:
+		for {
+			select {
+			case <-timer.C:
+				if err := s.Trigger(); err != nil {
+					log.Println("Skipping scheduled run:", err)
+				}
+				timer.Reset(time.Until(s.schedule.Next(time.Now())))
+			case <-ctx.Done():
+				break loop
+			}
+		}
+	} else {
+		<-ctx.Done()
+	}
+	s.Cancel()
+	s.runs.Wait()
+}
+
+// Trigger starts a run in the background. It returns ErrRunInProgress if a run
+// is going here or another instance holds the run lease.
+func (s *Scheduler) Trigger() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil || s.agg.Running() {
+		return ErrRunInProgress
+	}
+	acquiredAt, err := s.agg.leaseAcquiredAt(context.Background())
+	if err != nil {
+		return err
+	}
+	if !acquiredAt.IsZero() {
+		return ErrRunInProgress
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.startedAt = time.Now()
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		defer s.finished()
+		s.agg.Run(ctx, RunOptions{})
+	}()
+	return nil
+}
+
+func (s *Scheduler) finished() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel()
+	s.cancel = nil
+	s.startedAt = time.Time{}
+}
+
+// Cancel stops the run this scheduler started, which is recorded as aborted.
+// It reports whether there was a run to cancel.
+func (s *Scheduler) Cancel() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+// Status reports whether a run is in progress, whether this scheduler started
+// it or not, going by the aggregator and the run lease.
+func (s *Scheduler) Status(ctx context.Context) (SchedulerStatus, error) {
+	acquiredAt, err := s.agg.leaseAcquiredAt(ctx)
+	if err != nil {
+		return SchedulerStatus{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := SchedulerStatus{
+		Running:   s.cancel != nil || s.agg.Running() || !acquiredAt.IsZero(),
+		StartedAt: s.startedAt,
+		Schedule:  s.spec,
+	}
+	if !acquiredAt.IsZero() {
+		status.StartedAt = acquiredAt
+	}
+	return status, nil
+}
@@ -0,0 +1,39 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, 5, 1, 12, 30, 0, 0, time.Local)
+	for spec, expected := range map[string]time.Time{
+		"@hourly":      time.Date(2024, 5, 1, 13, 0, 0, 0, time.Local),
+		"@daily":       time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local),
+		"@weekly":      time.Date(2024, 5, 5, 0, 0, 0, 0, time.Local),
+		"@every 6h":    from.Add(6 * time.Hour),
+		" @every 90m ": from.Add(90 * time.Minute),
+		"0 3 * * *":    time.Date(2024, 5, 2, 3, 0, 0, 0, time.Local),
+		"*/15 * * * *": time.Date(2024, 5, 1, 12, 45, 0, 0, time.Local),
+		"0 9 * * 1-5":  time.Date(2024, 5, 2, 9, 0, 0, 0, time.Local),
+	} {
+		schedule, err := parseSchedule(spec)
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(expected) {
+			t.Errorf("%q: expected %v, got %v", spec, expected, got)
+		}
+	}
+
+	if schedule, err := parseSchedule(""); schedule != nil || err != nil {
+		t.Error("expected no schedule", schedule, err)
+	}
+
+	for _, spec := range []string{"0 * * *", "61 * * * *", "@every", "@every soon", "@every 1s", "@fortnightly"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
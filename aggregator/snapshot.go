@@ -0,0 +1,78 @@
+package aggregator
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// snapshotRetention thins out old snapshots: they're kept as taken for a month,
+// then only the last of each week is kept, and after a year the last of each month.
+var snapshotRetention = []struct {
+	age    time.Duration
+	bucket string
+}{
+	{age: 30 * 24 * time.Hour, bucket: "week"},
+	{age: 365 * 24 * time.Hour, bucket: "month"},
+}
+
+// snapshotUser appends the user's counts to their history under the run.
+func (a *Aggregator) snapshotUser(ctx context.Context, r *run, u sqlc.UpdateUserParams) error {
+	if r == nil || r.dryRun() {
+		return nil
+	}
+	err := a.queries.InsertUserSnapshot(ctx, sqlc.InsertUserSnapshotParams{
+		RunID:       r.id,
+		Login:       u.Login,
+		TakenAt:     u.RefreshedAt.Time,
+		Followers:   u.Followers.Int32,
+		PublicRepos: u.PublicRepos.Int32,
+	})
+	if err != nil {
+		log.Println("Error snapshotting user", u.Login, err)
+	}
+	return err
+}
+
+// snapshotRepo appends the repo's counts to its history under the run.
+func (a *Aggregator) snapshotRepo(ctx context.Context, r *run, repo sqlc.InsertRepoParams) error {
+	if r == nil || r.dryRun() {
+		return nil
+	}
+	err := a.queries.InsertRepoSnapshot(ctx, sqlc.InsertRepoSnapshotParams{
+		RunID:           r.id,
+		Owner:           repo.Owner,
+		Name:            repo.Name,
+		TakenAt:         repo.RefreshedAt.Time,
+		StargazersCount: repo.StargazersCount.Int32,
+		ForksCount:      repo.ForksCount.Int32,
+		WatchersCount:   repo.WatchersCount.Int32,
+		OpenIssuesCount: repo.OpenIssuesCount.Int32,
+	})
+	if err != nil {
+		log.Println("Error snapshotting repo", repo.Owner, repo.Name, err)
+	}
+	return err
+}
+
+// downsampleSnapshots applies snapshotRetention to the history.
+func (a *Aggregator) downsampleSnapshots(ctx context.Context) {
+	for _, retention := range snapshotRetention {
+		before := time.Now().Add(-retention.age)
+		repos, err := a.queries.DownsampleRepoSnapshots(ctx, sqlc.DownsampleRepoSnapshotsParams{Bucket: retention.bucket, Before: before})
+		if err != nil {
+			log.Println("Error downsampling repo snapshots", err)
+			return
+		}
+		users, err := a.queries.DownsampleUserSnapshots(ctx, sqlc.DownsampleUserSnapshotsParams{Bucket: retention.bucket, Before: before})
+		if err != nil {
+			log.Println("Error downsampling user snapshots", err)
+			return
+		}
+		if repos > 0 || users > 0 {
+			log.Printf("Downsampled %v repo and %v user snapshots older than %v to one a %v", repos, users, retention.age, retention.bucket)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package aggregator
+
+import (
+	"fmt"
+	"time"
+)
+
+// searchCap is the most results GitHub's search API returns for a single query.
+const searchCap = 1000
+
+// firstAccountYear is the year the oldest GitHub accounts were created.
+const firstAccountYear = 2007
+
+type granularity int
+
+const (
+	byYear granularity = iota
+	byMonth
+	byDay
+)
+
+// window is an inclusive range of account creation dates to search. Windows
+// that hit the search cap are split into months, and months into days.
+type window struct {
+	from, to time.Time
+	unit     granularity
+}
+
+func (w window) qualifier() string {
+	const layout = "2006-01-02"
+	return fmt.Sprintf("created:%v..%v", w.from.Format(layout), w.to.Format(layout))
+}
+
+// yearWindows returns a window per year from the first GitHub accounts until
+// the year of now, so the list never needs to be maintained by hand.
+func yearWindows(now time.Time) []window {
+	var windows []window
+	for year := firstAccountYear; year <= now.Year(); year++ {
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		windows = append(windows, window{from: from, to: from.AddDate(1, 0, -1), unit: byYear})
+	}
+	return windows
+}
+
+// split breaks the window into the next smaller unit, or returns nil for a
+// single day which can't be split any further.
+func (w window) split() []window {
+	var step func(time.Time) time.Time
+	var unit granularity
+	switch w.unit {
+	case byYear:
+		step, unit = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, byMonth
+	case byMonth:
+		step, unit = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, byDay
+	default:
+		return nil
+	}
+	var windows []window
+	for from := w.from; !from.After(w.to); from = step(from) {
+		windows = append(windows, window{from: from, to: step(from).AddDate(0, 0, -1), unit: unit})
+	}
+	return windows
+}
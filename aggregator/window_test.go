@@ -0,0 +1,50 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestYearWindows(t *testing.T) {
+	now := time.Date(2031, time.March, 4, 0, 0, 0, 0, time.UTC)
+	windows := yearWindows(now)
+	if len(windows) != 2031-firstAccountYear+1 {
+		t.Fatalf("expected a window per year, got %v", len(windows))
+	}
+	if got := windows[0].qualifier(); got != "created:2007-01-01..2007-12-31" {
+		t.Error(got)
+	}
+	if got := windows[len(windows)-1].qualifier(); got != "created:2031-01-01..2031-12-31" {
+		t.Error(got)
+	}
+	for i := 1; i < len(windows); i++ {
+		if !windows[i].from.Equal(windows[i-1].to.AddDate(0, 0, 1)) {
+			t.Errorf("gap between %v and %v", windows[i-1].qualifier(), windows[i].qualifier())
+		}
+	}
+}
+
+func TestWindowSplit(t *testing.T) {
+	year := yearWindows(time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC))
+	leap := year[len(year)-1]
+
+	months := leap.split()
+	if len(months) != 12 {
+		t.Fatalf("expected 12 months, got %v", len(months))
+	}
+	if got := months[1].qualifier(); got != "created:2024-02-01..2024-02-29" {
+		t.Error(got)
+	}
+
+	days := months[1].split()
+	if len(days) != 29 {
+		t.Fatalf("expected 29 days, got %v", len(days))
+	}
+	if got := days[28].qualifier(); got != "created:2024-02-29..2024-02-29" {
+		t.Error(got)
+	}
+
+	if days[0].split() != nil {
+		t.Error("a day should not split")
+	}
+}
@@ -27,7 +27,7 @@ func main() {
 	httpClient := oauth2.NewClient(context.Background(), ts)
 	client := github.NewClient(httpClient)
 
-	u, _ := aggregator.FindInStl(client, "user")
+	u, _ := aggregator.FindInStl(context.Background(), client, cfg.Region, "user")
 	for k := range u {
 		fmt.Println(k)
 	}
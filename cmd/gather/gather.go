@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jakecoffman/stldevs/aggregator"
@@ -13,6 +20,24 @@ import (
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	fresh := flag.Bool("fresh", false, "abort any unfinished run and start over instead of resuming it")
+	dryRun := flag.Bool("dry-run", false, "read from GitHub but write nothing, printing what would change instead")
+	diffFormat := flag.String("diff", "text", "how a dry run prints its changes, text or json")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintln(out, "Usage: gather [-fresh] [-dry-run [-diff json]] [command]")
+		fmt.Fprintln(out, "Without a command, discovers every user in the region and refreshes them.")
+		fmt.Fprintln(out, "Commands:")
+		fmt.Fprintln(out, "  user <login>           refresh a single user and their repos")
+		fmt.Fprintln(out, "  orgs                   refresh the registered organizations")
+		fmt.Fprintln(out, "  stale [-older 168h]    refresh users last refreshed longer ago than -older")
+		fmt.Fprintln(out, "  discover               find users and refresh their profiles but not their repos")
+		fmt.Fprintln(out, "Flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	opts := parseCommand(flag.Args())
+	opts.Fresh = *fresh
 
 	f, err := os.Open("./config.json")
 	if err != nil {
@@ -33,11 +58,62 @@ func main() {
 		log.Fatal(err)
 	}
 
-	log.Println("MIGRATE")
-	if err = migrations.Migrate(db); err != nil {
-		log.Fatal("Could not migrate schema")
+	// a dry run writes nothing, so it expects the schema to be up to date already
+	if !*dryRun {
+		log.Println("MIGRATE")
+		if err = migrations.Migrate(db); err != nil {
+			log.Fatal("Could not migrate schema")
+		}
 	}
 
-	agg := aggregator.New(db, cfg.GithubKey)
-	agg.Run()
+	// deploys stop gather with SIGTERM, the run is recorded as aborted and
+	// picked up again by the next gather
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	agg := aggregator.New(db, cfg)
+	if *dryRun {
+		diff, err := agg.DryRun(ctx, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *diffFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err = enc.Encode(diff); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		fmt.Print(diff.Summary())
+		return
+	}
+	agg.Run(ctx, opts)
+}
+
+// parseCommand turns the command line after the flags into the run to do.
+func parseCommand(args []string) aggregator.RunOptions {
+	if len(args) == 0 {
+		return aggregator.RunOptions{Mode: aggregator.ModeFull}
+	}
+	switch args[0] {
+	case aggregator.ModeFull, aggregator.ModeOrgs, aggregator.ModeDiscover:
+		if len(args) == 1 {
+			return aggregator.RunOptions{Mode: args[0]}
+		}
+	case aggregator.ModeUser:
+		if len(args) == 2 {
+			return aggregator.RunOptions{Mode: aggregator.ModeUser, Login: args[1]}
+		}
+	case aggregator.ModeStale:
+		stale := flag.NewFlagSet("stale", flag.ExitOnError)
+		older := stale.Duration("older", 7*24*time.Hour, "refresh users last refreshed longer ago than this")
+		_ = stale.Parse(args[1:])
+		if stale.NArg() == 0 {
+			return aggregator.RunOptions{Mode: aggregator.ModeStale, OlderThan: *older}
+		}
+	}
+	flag.Usage()
+	os.Exit(2)
+	return aggregator.RunOptions{}
 }
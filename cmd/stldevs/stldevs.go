@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/jakecoffman/stldevs/aggregator"
 	"github.com/jakecoffman/stldevs/config"
 	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/sessions"
 	"github.com/jakecoffman/stldevs/web"
 	"log"
 	"os"
+	"time"
 )
 
 func main() {
@@ -22,5 +29,23 @@ func main() {
 
 	db.Connect(cfg)
 	db.Migrate()
-	web.Run(cfg)
+	agg := aggregator.New(db.DB(), cfg)
+	scheduler, err := aggregator.NewScheduler(agg, cfg.Schedule)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// deploys stop the server with SIGTERM, the runs in progress, scheduled or
+	// opted in, are recorded as aborted before exiting and picked up again by
+	// the next run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sessions.Store = sessions.NewPostgresStore(db.DB())
+	go sessions.StartSweeper(ctx, sessions.Store, time.Hour)
+	go func() {
+		web.Run(cfg, agg, scheduler)
+		stop()
+	}()
+	scheduler.Start(ctx)
 }
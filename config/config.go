@@ -12,10 +12,47 @@ type Config struct {
 	GithubClientSecret,
 	SessionSecret string
 	Environment string
+	// Workers is how many users the aggregator refreshes in parallel.
+	Workers int
+	// Region is the area whose developers are gathered, St. Louis if not set.
+	Region Region
+	// Schedule is when the web server runs the aggregator, a cron expression
+	// such as "0 3 * * *", "@daily" or "@every 6h". Left empty, runs only
+	// happen when an admin asks for one.
+	Schedule string
+	// TrustedOrigins are other origins allowed to make changes with the session
+	// cookie, such as the UI's dev server at "http://localhost:3000".
+	TrustedOrigins []string
+}
+
+// Region defines the geography the aggregator searches for developers.
+type Region struct {
+	// Name is how the region is referred to, e.g. "St. Louis".
+	Name string
+	// Locations are the profile locations GitHub is searched for, e.g. "STL" or "Kirkwood".
+	Locations []string
+	// Exclude are places that match a location but aren't in the region, e.g. "St. Louis Park".
+	Exclude []string
+	// Orgs is the path to a newline separated list of organizations to always
+	// include. They're imported into the org registry at the start of each run,
+	// which is otherwise seeded with the St. Louis list.
+	Orgs string
+}
+
+// DefaultRegion is St. Louis, the region this project was built for.
+var DefaultRegion = Region{
+	Name:      "St. Louis",
+	Locations: []string{"St. Louis", "STL", "St Louis", "Saint Louis"},
+	Exclude:   []string{"St. Louis Park", "Senegal", "Sénégal"},
 }
 
 func NewConfig(r io.Reader) (*Config, error) {
 	cfg := &Config{}
 	err := json.NewDecoder(r).Decode(cfg)
+	if len(cfg.Region.Locations) == 0 {
+		cfg.Region.Name = DefaultRegion.Name
+		cfg.Region.Locations = DefaultRegion.Locations
+		cfg.Region.Exclude = DefaultRegion.Exclude
+	}
 	return cfg, err
 }
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// Audited actions, the before and after of each are JSON objects of the
+// fields changed, or null when there's nothing on that side.
+const (
+	ActionHideUser    = "user.hide"
+	ActionOptOut      = "user.opt_out"
+	ActionDeleteUser  = "user.delete"
+	ActionGrantRole   = "role.grant"
+	ActionRevokeRole  = "role.revoke"
+	ActionAddOrg      = "org.add"
+	ActionAnnotateOrg = "org.annotate"
+	ActionRemoveOrg   = "org.remove"
+)
+
+// SystemActor is who changes are attributed to when no user made them.
+const SystemActor = "system"
+
+type actorKey struct{}
+
+// WithActor attributes the changes made with the context to the user.
+func WithActor(ctx context.Context, login string) context.Context {
+	return context.WithValue(ctx, actorKey{}, login)
+}
+
+func actor(ctx context.Context) string {
+	if login, ok := ctx.Value(actorKey{}).(string); ok && login != "" {
+		return login
+	}
+	return SystemActor
+}
+
+// change is an audit entry to record, see audited.
+type change struct {
+	action, target string
+	before, after  any
+}
+
+// deletedUser is what the audit log keeps of a deleted user, enough to tell
+// who was removed and how much with them, but none of the profile they may
+// have asked to have removed.
+type deletedUser struct {
+	Login       string `json:"login"`
+	Type        string `json:"type"`
+	Hide        bool   `json:"hide"`
+	Followers   int32  `json:"followers"`
+	PublicRepos int32  `json:"public_repos"`
+	Stars       int32  `json:"stars"`
+	Forks       int32  `json:"forks"`
+}
+
+// audited runs mutate in a transaction with the audit entry it describes, so
+// nothing is changed without being recorded. A nil change records nothing,
+// for when nothing changed.
+func audited(ctx context.Context, mutate func(q *sqlc.Queries) (*change, error)) error {
+	if queries == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer tx.Rollback()
+	txQueries := queries.WithTx(tx)
+	c, err := mutate(txQueries)
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		before, err := json.Marshal(c.before)
+		if err != nil {
+			return err
+		}
+		after, err := json.Marshal(c.after)
+		if err != nil {
+			return err
+		}
+		err = txQueries.InsertAudit(ctx, sqlc.InsertAuditParams{
+			Actor:     actor(ctx),
+			Action:    c.action,
+			Target:    c.target,
+			Before:    before,
+			After:     after,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			log.Println("InsertAudit failed:", err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AuditFilter narrows the audit log, empty fields match everything.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+}
+
+// Audit returns the audit log, newest first.
+var Audit = func(ctx context.Context, filter AuditFilter, page PageRequest) *Page[sqlc.AggAudit] {
+	if queries == nil {
+		return nil
+	}
+	params := sqlc.ListAuditParams{
+		Actor:  nullString(filter.Actor),
+		Action: nullString(filter.Action),
+		Target: nullString(filter.Target),
+		Since:  sql.NullTime{Time: filter.Since, Valid: !filter.Since.IsZero()},
+		Limit:  int32(page.Limit),
+		Offset: int32(page.Offset),
+	}
+	rows, err := queries.ListAudit(ctx, params)
+	if err != nil {
+		log.Println("ListAudit query failed:", err)
+		return nil
+	}
+	total, err := pageTotal(rows, func(row sqlc.ListAuditRow) int64 { return row.Total }, func() (int64, error) {
+		return queries.CountAudit(ctx, sqlc.CountAuditParams{
+			Actor:  params.Actor,
+			Action: params.Action,
+			Target: params.Target,
+			Since:  params.Since,
+		})
+	}, page)
+	if err != nil {
+		log.Println("CountAudit query failed:", err)
+		return nil
+	}
+	entries := make([]sqlc.AggAudit, len(rows))
+	for i, row := range rows {
+		entries[i] = row.AggAudit
+	}
+	return newPage(entries, total, page)
+}
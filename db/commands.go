@@ -3,20 +3,23 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/jakecoffman/stldevs/db/sqlc"
 )
 
-// LastRun returns the last time github was scraped.
-var LastRun = func() time.Time {
+// LastRun returns the last time a full scrape of github finished successfully,
+// targeted runs only refresh part of it.
+var LastRun = func(ctx context.Context) time.Time {
 	if queries == nil {
 		return time.Time{}
 	}
-	lastRun, err := queries.LastRun(context.Background())
+	lastRun, err := queries.LastRun(ctx)
 	if err == sql.ErrNoRows {
 		return time.Time{}
 	}
@@ -24,14 +27,54 @@ var LastRun = func() time.Time {
 		log.Println("LastRun query failed:", err)
 		return time.Time{}
 	}
-	return lastRun
+	return lastRun.Time
 }
 
-var PopularLanguages = func() []sqlc.PopularLanguagesRow {
+// Runs returns the most recent aggregator runs, newest first.
+var Runs = func(ctx context.Context, limit int) []sqlc.ListRunsRow {
 	if queries == nil {
 		return nil
 	}
-	rows, err := queries.PopularLanguages(context.Background())
+	rows, err := queries.ListRuns(ctx, int32(limit))
+	if err != nil {
+		log.Println("ListRuns query failed:", err)
+		return nil
+	}
+	if rows == nil {
+		// nil is a failure, an empty history is still a list
+		rows = []sqlc.ListRunsRow{}
+	}
+	return rows
+}
+
+type RunData struct {
+	Run    sqlc.AggRun        `json:"run"`
+	Errors []sqlc.AggRunError `json:"errors"`
+}
+
+// Run returns a single aggregator run along with the errors it encountered.
+var Run = func(ctx context.Context, id int64) (*RunData, error) {
+	if queries == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	run, err := queries.GetRun(ctx, id)
+	if err != nil {
+		log.Println("Error querying run", id, err)
+		return nil, err
+	}
+	errors, err := queries.RunErrors(ctx, id)
+	if err != nil {
+		log.Println("Error querying run errors", id, err)
+		return nil, err
+	}
+	return &RunData{Run: run, Errors: errors}, nil
+}
+
+var PopularLanguages = func(ctx context.Context) []sqlc.PopularLanguagesRow {
+	if queries == nil {
+		return nil
+	}
+	rows, err := queries.PopularLanguages(ctx)
 	if err != nil {
 		log.Println("PopularLanguages query failed:", err)
 		return nil
@@ -39,7 +82,7 @@ var PopularLanguages = func() []sqlc.PopularLanguagesRow {
 	return rows
 }
 
-var PopularDevs = func(devType, company, sortBy string) []sqlc.PopularDevsRow {
+var PopularDevs = func(ctx context.Context, devType, company, sortBy string, page PageRequest) *Page[sqlc.PopularDevsRow] {
 	if queries == nil {
 		return nil
 	}
@@ -61,18 +104,30 @@ var PopularDevs = func(devType, company, sortBy string) []sqlc.PopularDevsRow {
 	params := sqlc.PopularDevsParams{
 		DevType: sql.NullString{String: devType, Valid: devType != ""},
 		SortBy:  sortBy,
+		Limit:   int32(page.Limit),
+		Offset:  int32(page.Offset),
 	}
 	if company != "" {
 		params.CompanyPattern = sql.NullString{String: "%" + company + "%", Valid: true}
 	} else {
 		params.CompanyPattern = sql.NullString{Valid: false}
 	}
-	rows, err := queries.PopularDevs(context.Background(), params)
+	rows, err := queries.PopularDevs(ctx, params)
 	if err != nil {
 		log.Println("PopularDevs query failed:", err)
 		return nil
 	}
-	return rows
+	result, err := countedPage(rows, func(row sqlc.PopularDevsRow) int64 { return row.Total }, func() (int64, error) {
+		return queries.CountPopularDevs(ctx, sqlc.CountPopularDevsParams{
+			DevType:        params.DevType,
+			CompanyPattern: params.CompanyPattern,
+		})
+	}, page)
+	if err != nil {
+		log.Println("CountPopularDevs query failed:", err)
+		return nil
+	}
+	return result
 }
 
 type LanguageResult struct {
@@ -85,19 +140,32 @@ type LanguageResult struct {
 
 var languageCache = struct {
 	sync.RWMutex
-	result  map[string][]*LanguageResult
+	result  map[languagePage]*Page[*LanguageResult]
 	lastRun time.Time
 }{
-	result: map[string][]*LanguageResult{},
+	result: map[languagePage]*Page[*LanguageResult]{},
+}
+
+type languagePage struct {
+	name string
+	page PageRequest
 }
 
-var Language = func(name string) []*LanguageResult {
+// languageCacheSize is the most pages kept between runs, so paging with odd
+// limits and offsets can't grow the cache without bound.
+const languageCacheSize = 256
+
+// Language lists the devs with the most stars in the language, with their top
+// three repos in it. Pages are cached until the next run finishes. Empty pages
+// aren't cached, so made up names can't grow the cache.
+var Language = func(ctx context.Context, name string, page PageRequest) *Page[*LanguageResult] {
 	if queries == nil {
 		return nil
 	}
-	run := LastRun()
+	run := LastRun(ctx)
+	key := languagePage{name: strings.ToLower(name), page: page}
 	languageCache.RLock()
-	result, found := languageCache.result[name]
+	result, found := languageCache.result[key]
 	if found && run.Equal(languageCache.lastRun) {
 		languageCache.RUnlock()
 		return result
@@ -105,12 +173,27 @@ var Language = func(name string) []*LanguageResult {
 	languageCache.RUnlock()
 	languageCache.Lock()
 	defer languageCache.Unlock()
+	if !run.Equal(languageCache.lastRun) || len(languageCache.result) >= languageCacheSize {
+		languageCache.result = map[languagePage]*Page[*LanguageResult]{}
+		languageCache.lastRun = run
+	}
 
-	rows, err := queries.LanguageLeaders(context.Background(), name)
+	rows, err := queries.LanguageLeaders(ctx, sqlc.LanguageLeadersParams{
+		Language: name,
+		Limit:    int32(page.Limit),
+		Offset:   int32(page.Offset),
+	})
 	if err != nil {
 		log.Println("LanguageLeaders query failed:", err)
 		return nil
 	}
+	total, err := pageTotal(rows, func(row sqlc.LanguageLeadersRow) int64 { return row.Total }, func() (int64, error) {
+		return queries.CountLanguageLeaders(ctx, name)
+	}, page)
+	if err != nil {
+		log.Println("CountLanguageLeaders query failed:", err)
+		return nil
+	}
 	var cursor *LanguageResult
 	results := make([]*LanguageResult, 0, len(rows))
 	for _, row := range rows {
@@ -127,16 +210,18 @@ var Language = func(name string) []*LanguageResult {
 		}
 		cursor.Repos = append(cursor.Repos, row)
 	}
-	languageCache.result[name] = results
-	languageCache.lastRun = run
-	return results
+	result = newPage(results, total, page)
+	if len(results) > 0 {
+		languageCache.result[key] = result
+	}
+	return result
 }
 
-func GetUser(login string) (sqlc.GetUserRow, error) {
+var GetUser = func(ctx context.Context, login string) (sqlc.GetUserRow, error) {
 	if queries == nil {
 		return sqlc.GetUserRow{}, fmt.Errorf("database not initialized")
 	}
-	row, err := queries.GetUser(context.Background(), login)
+	row, err := queries.GetUser(ctx, login)
 	if err != nil {
 		log.Println("Error querying user", login, err)
 		return sqlc.GetUserRow{}, err
@@ -149,7 +234,7 @@ type ProfileData struct {
 	Repos map[string][]sqlc.ReposForUserRow `json:"repos"`
 }
 
-var Profile = func(name string) (*ProfileData, error) {
+var Profile = func(ctx context.Context, name string) (*ProfileData, error) {
 	if queries == nil {
 		return nil, fmt.Errorf("database not initialized")
 	}
@@ -159,7 +244,7 @@ var Profile = func(name string) (*ProfileData, error) {
 	defer close(reposCh)
 
 	go func() {
-		user, err := GetUser(name)
+		user, err := GetUser(ctx, name)
 		if err != nil {
 			userCh <- sqlc.GetUserRow{}
 			return
@@ -168,7 +253,7 @@ var Profile = func(name string) (*ProfileData, error) {
 	}()
 
 	go func() {
-		rows, err := queries.ReposForUser(context.Background(), name)
+		rows, err := queries.ReposForUser(ctx, name)
 		if err != nil {
 			log.Println("Error querying repo for user", name, err)
 			reposCh <- nil
@@ -191,58 +276,382 @@ var Profile = func(name string) (*ProfileData, error) {
 	return &ProfileData{User: user, Repos: repoMap}, nil
 }
 
-var SearchUsers = func(term string) []sqlc.SearchUsersRow {
+// SearchUsers ranks devs by how well their login, name, company and bio match
+// the query, blended with their stars. See ParseSearch for the syntax.
+var SearchUsers = func(ctx context.Context, term string, page PageRequest) *Page[sqlc.SearchUsersRow] {
 	if queries == nil {
 		return nil
 	}
-	pattern := "%" + term + "%"
-	rows, err := queries.SearchUsers(context.Background(), pattern)
+	query := ParseSearch(term)
+	rows, err := searchUsers(ctx, query, page)
 	if err != nil {
-		log.Println("SearchUsers query failed:", err)
 		return nil
 	}
-	return rows
+	result, err := countedPage(rows, func(row sqlc.SearchUsersRow) int64 { return row.Total }, func() (int64, error) {
+		return queries.CountSearchUsers(ctx, sqlc.CountSearchUsersParams{
+			Query:    query.Text,
+			DevType:  nullString(query.Type),
+			Company:  nullString(query.Company),
+			Language: nullString(query.Language),
+		})
+	}, page)
+	if err != nil {
+		log.Println("CountSearchUsers query failed:", err)
+		return nil
+	}
+	return result
+}
+
+// SearchRepos ranks repos by how well their name, description and language
+// match the query, blended with their stars. See ParseSearch for the syntax.
+var SearchRepos = func(ctx context.Context, term string, page PageRequest) *Page[sqlc.SearchReposRow] {
+	if queries == nil {
+		return nil
+	}
+	query := ParseSearch(term)
+	rows, err := searchRepos(ctx, query, page)
+	if err != nil {
+		return nil
+	}
+	result, err := countedPage(rows, func(row sqlc.SearchReposRow) int64 { return row.Total }, func() (int64, error) {
+		return queries.CountSearchRepos(ctx, sqlc.CountSearchReposParams{
+			Query:    query.Text,
+			Language: nullString(query.Language),
+			Owner:    nullString(query.User),
+			DevType:  nullString(query.Type),
+			Company:  nullString(query.Company),
+		})
+	}, page)
+	if err != nil {
+		log.Println("CountSearchRepos query failed:", err)
+		return nil
+	}
+	return result
+}
+
+var HideUser = func(ctx context.Context, hide bool, login string) error {
+	return audited(ctx, func(q *sqlc.Queries) (*change, error) {
+		hidden, err := q.UserHidden(ctx, login)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("affected no users")
+		}
+		if err != nil {
+			log.Println("UserHidden query failed:", err)
+			return nil, err
+		}
+		if _, err = q.HideUser(ctx, sqlc.HideUserParams{Hide: hide, Login: login}); err != nil {
+			log.Println("HideUser update failed:", err)
+			return nil, err
+		}
+		if hidden == hide {
+			return nil, nil
+		}
+		return &change{
+			action: ActionHideUser,
+			target: login,
+			before: map[string]bool{"hide": hidden},
+			after:  map[string]bool{"hide": hide},
+		}, nil
+	})
+}
+
+// SetOptOut records that the user never wants to be listed, or clears it. An
+// opted out user is hidden and the aggregator won't gather them again, even if
+// their row is deleted. Opting out also withdraws any opt in.
+var SetOptOut = func(ctx context.Context, login string, optOut bool, requestedBy string) error {
+	return audited(ctx, func(q *sqlc.Queries) (*change, error) {
+		optedOut, err := q.IsOptedOut(ctx, login)
+		if err != nil {
+			log.Println("IsOptedOut query failed:", err)
+			return nil, err
+		}
+		if optOut {
+			err = q.InsertOptOut(ctx, sqlc.InsertOptOutParams{
+				Login:       login,
+				RequestedBy: requestedBy,
+				RequestedAt: time.Now(),
+			})
+			if err != nil {
+				log.Println("InsertOptOut failed:", err)
+				return nil, err
+			}
+			if err = q.DeleteOptIn(ctx, login); err != nil {
+				log.Println("DeleteOptIn failed:", err)
+				return nil, err
+			}
+		} else if _, err = q.DeleteOptOut(ctx, login); err != nil {
+			log.Println("DeleteOptOut failed:", err)
+			return nil, err
+		}
+		// the user may not have been gathered yet, or may have been deleted
+		if _, err = q.HideUser(ctx, sqlc.HideUserParams{Hide: optOut, Login: login}); err != nil {
+			log.Println("HideUser update failed:", err)
+			return nil, err
+		}
+		if optedOut == optOut {
+			return nil, nil
+		}
+		return &change{
+			action: ActionOptOut,
+			target: login,
+			before: map[string]bool{"opted_out": optedOut},
+			after:  map[string]bool{"opted_out": optOut},
+		}, nil
+	})
 }
 
-var SearchRepos = func(term string) []sqlc.SearchReposRow {
+// Delete removes the user and their repos. The audit log keeps only who the
+// user was and their counts, see deletedUser.
+var Delete = func(ctx context.Context, login string) error {
+	return audited(ctx, func(q *sqlc.Queries) (*change, error) {
+		deleted := &change{action: ActionDeleteUser, target: login}
+		user, err := q.GetUser(ctx, login)
+		if err == nil {
+			deleted.before = deletedUser{
+				Login:       user.Login,
+				Type:        user.Type,
+				Hide:        user.Hide,
+				Followers:   user.Followers,
+				PublicRepos: user.PublicRepos,
+				Stars:       user.Stars,
+				Forks:       user.Forks,
+			}
+		} else if err != sql.ErrNoRows {
+			log.Println("Error querying user", login, err)
+			return nil, err
+		}
+		if err = q.DeleteReposByOwner(ctx, login); err != nil {
+			log.Println("Failed deleting repos for", login, err)
+			return nil, err
+		}
+		if err = q.DeleteUser(ctx, login); err != nil {
+			log.Println("Failed deleting user", login, err)
+			return nil, err
+		}
+		return deleted, nil
+	})
+}
+
+var LocationOverrides = func(ctx context.Context) []sqlc.AggLocationOverride {
 	if queries == nil {
 		return nil
 	}
-	pattern := "%" + term + "%"
-	rows, err := queries.SearchRepos(context.Background(), pattern)
+	rows, err := queries.ListLocationOverrides(ctx)
 	if err != nil {
-		log.Println("SearchRepos query failed:", err)
+		log.Println("ListLocationOverrides query failed:", err)
 		return nil
 	}
+	if rows == nil {
+		rows = []sqlc.AggLocationOverride{}
+	}
 	return rows
 }
 
-var HideUser = func(hide bool, login string) error {
+// SetLocationOverride forces a user into or out of the region regardless of
+// their location, taking effect immediately rather than on the next run.
+var SetLocationOverride = func(ctx context.Context, login string, include bool, reason, createdBy string) error {
 	if queries == nil {
 		return fmt.Errorf("database not initialized")
 	}
-	affected, err := queries.HideUser(context.Background(), sqlc.HideUserParams{Hide: hide, Login: login})
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Println("HideUser update failed:", err)
+		log.Println(err)
 		return err
 	}
-	if affected != 1 {
-		return fmt.Errorf("affected no users")
+	defer tx.Rollback()
+	txQueries := queries.WithTx(tx)
+	err = txQueries.UpsertLocationOverride(ctx, sqlc.UpsertLocationOverrideParams{
+		Login:     login,
+		Include:   include,
+		Reason:    reason,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Println("UpsertLocationOverride failed:", err)
+		return err
 	}
-	return nil
+	err = txQueries.SetUserOutsideRegion(ctx, sqlc.SetUserOutsideRegionParams{Login: login, OutsideRegion: !include})
+	if err != nil {
+		log.Println("SetUserOutsideRegion failed:", err)
+		return err
+	}
+	return tx.Commit()
 }
 
-var Delete = func(login string) error {
+// DeleteLocationOverride goes back to classifying the user by their location
+// on the next run.
+var DeleteLocationOverride = func(ctx context.Context, login string) error {
 	if queries == nil {
 		return fmt.Errorf("database not initialized")
 	}
-	if err := queries.DeleteReposByOwner(context.Background(), login); err != nil {
-		log.Println("Failed deleting repos for", login, err)
+	affected, err := queries.DeleteLocationOverride(ctx, login)
+	if err != nil {
+		log.Println("DeleteLocationOverride failed:", err)
 		return err
 	}
-	if err := queries.DeleteUser(context.Background(), login); err != nil {
-		log.Println("Failed deleting user", login, err)
-		return err
+	if affected != 1 {
+		return fmt.Errorf("no override for %v", login)
 	}
 	return nil
 }
+
+var Orgs = func(ctx context.Context) []sqlc.AggOrgRegistry {
+	if queries == nil {
+		return nil
+	}
+	rows, err := queries.ListOrgs(ctx)
+	if err != nil {
+		log.Println("ListOrgs query failed:", err)
+		return nil
+	}
+	if rows == nil {
+		rows = []sqlc.AggOrgRegistry{}
+	}
+	return rows
+}
+
+// ErrOrgRegistered is returned when adding an organization that's already registered.
+var ErrOrgRegistered = errors.New("already registered")
+
+// AddOrg registers an organization so every run includes it.
+var AddOrg = func(ctx context.Context, login, reason, addedBy string) error {
+	return audited(ctx, func(q *sqlc.Queries) (*change, error) {
+		affected, err := q.InsertOrg(ctx, sqlc.InsertOrgParams{
+			Login:     login,
+			Reason:    reason,
+			AddedBy:   addedBy,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			log.Println("InsertOrg failed:", err)
+			return nil, err
+		}
+		if affected != 1 {
+			return nil, fmt.Errorf("%v is %w", login, ErrOrgRegistered)
+		}
+		return &change{action: ActionAddOrg, target: login, after: map[string]string{"reason": reason}}, nil
+	})
+}
+
+var AnnotateOrg = func(ctx context.Context, login, reason string) error {
+	return audited(ctx, func(q *sqlc.Queries) (*change, error) {
+		org, err := q.GetOrg(ctx, login)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%v is not registered", login)
+		}
+		if err != nil {
+			log.Println("GetOrg query failed:", err)
+			return nil, err
+		}
+		if _, err = q.UpdateOrgReason(ctx, sqlc.UpdateOrgReasonParams{Login: login, Reason: reason}); err != nil {
+			log.Println("UpdateOrgReason failed:", err)
+			return nil, err
+		}
+		if org.Reason == reason {
+			return nil, nil
+		}
+		return &change{
+			action: ActionAnnotateOrg,
+			target: login,
+			before: map[string]string{"reason": org.Reason},
+			after:  map[string]string{"reason": reason},
+		}, nil
+	})
+}
+
+var RemoveOrg = func(ctx context.Context, login string) error {
+	return audited(ctx, func(q *sqlc.Queries) (*change, error) {
+		org, err := q.GetOrg(ctx, login)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%v is not registered", login)
+		}
+		if err != nil {
+			log.Println("GetOrg query failed:", err)
+			return nil, err
+		}
+		if _, err = q.DeleteOrg(ctx, login); err != nil {
+			log.Println("DeleteOrg failed:", err)
+			return nil, err
+		}
+		return &change{action: ActionRemoveOrg, target: login, before: org}, nil
+	})
+}
+
+// RepoHistory returns the snapshots of a repo taken since the given time, oldest first.
+var RepoHistory = func(ctx context.Context, owner, name string, since time.Time) []sqlc.RepoHistoryRow {
+	if queries == nil {
+		return nil
+	}
+	rows, err := queries.RepoHistory(ctx, sqlc.RepoHistoryParams{Owner: owner, Name: name, TakenAt: since})
+	if err != nil {
+		log.Println("RepoHistory query failed:", err)
+		return nil
+	}
+	if rows == nil {
+		rows = []sqlc.RepoHistoryRow{}
+	}
+	return rows
+}
+
+// UserHistory returns the snapshots of a user taken since the given time, oldest
+// first, with the stars and forks of their repos at each one.
+var UserHistory = func(ctx context.Context, login string, since time.Time) []sqlc.UserHistoryRow {
+	if queries == nil {
+		return nil
+	}
+	rows, err := queries.UserHistory(ctx, sqlc.UserHistoryParams{Login: login, TakenAt: since})
+	if err != nil {
+		log.Println("UserHistory query failed:", err)
+		return nil
+	}
+	if rows == nil {
+		rows = []sqlc.UserHistoryRow{}
+	}
+	return rows
+}
+
+// TrendingDevs ranks developers by how many stars their repos, or followers
+// they, gained over the last days, sorting by "stars" unless "followers" is given.
+var TrendingDevs = func(ctx context.Context, days int, language, devType, sortBy string) []sqlc.TrendingDevsRow {
+	if queries == nil {
+		return nil
+	}
+	if sortBy != "followers" {
+		sortBy = "stars"
+	}
+	rows, err := queries.TrendingDevs(ctx, sqlc.TrendingDevsParams{
+		Since:    time.Now().AddDate(0, 0, -days),
+		Language: sql.NullString{String: language, Valid: language != ""},
+		DevType:  sql.NullString{String: devType, Valid: devType != ""},
+		SortBy:   sortBy,
+	})
+	if err != nil {
+		log.Println("TrendingDevs query failed:", err)
+		return nil
+	}
+	if rows == nil {
+		rows = []sqlc.TrendingDevsRow{}
+	}
+	return rows
+}
+
+// TrendingRepos ranks repos by how many stars they gained over the last days.
+var TrendingRepos = func(ctx context.Context, days int, language, devType string) []sqlc.TrendingReposRow {
+	if queries == nil {
+		return nil
+	}
+	rows, err := queries.TrendingRepos(ctx, sqlc.TrendingReposParams{
+		Since:    time.Now().AddDate(0, 0, -days),
+		Language: sql.NullString{String: language, Valid: language != ""},
+		DevType:  sql.NullString{String: devType, Valid: devType != ""},
+	})
+	if err != nil {
+		log.Println("TrendingRepos query failed:", err)
+		return nil
+	}
+	if rows == nil {
+		rows = []sqlc.TrendingReposRow{}
+	}
+	return rows
+}
@@ -1,7 +1,12 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,9 +19,26 @@ func init() {
 	Connect(&config.Config{
 		Postgres: "postgres://postgres:pw@127.0.0.1:5432/postgres",
 	})
+	mustExec("drop table if exists agg_repo_snapshot")
+	mustExec("drop table if exists agg_user_snapshot")
+	mustExec("drop table if exists agg_run_user")
+	mustExec("drop table if exists agg_run_error")
+	mustExec("drop table if exists agg_run")
 	mustExec("drop table if exists agg_meta")
 	mustExec("drop table if exists agg_repo")
 	mustExec("drop table if exists agg_user")
+	mustExec("drop table if exists agg_http_cache")
+	mustExec("drop table if exists agg_location_override")
+	mustExec("drop table if exists agg_org_registry")
+	mustExec("drop table if exists agg_org_import")
+	mustExec("drop table if exists agg_opt_in")
+	mustExec("drop table if exists agg_opt_out")
+	mustExec("drop table if exists agg_session")
+	mustExec("drop table if exists agg_audit")
+	mustExec("drop table if exists agg_user_role")
+	mustExec("drop table if exists agg_role_permission")
+	mustExec("drop table if exists agg_role")
+	mustExec("drop table if exists agg_lease")
 	mustExec("drop table if exists migrations")
 	Migrate()
 }
@@ -29,21 +51,57 @@ func TestMigrate(t *testing.T) {
 }
 
 func TestLastRun(t *testing.T) {
-	if v := LastRun(); !v.Equal(time.Time{}) {
+	if v := LastRun(context.Background()); !v.Equal(time.Time{}) {
 		t.Errorf("Time should have been zero value, got %v", v)
 	}
-	mustExec("insert into agg_meta values (CURRENT_TIMESTAMP)")
-	if v := LastRun(); !v.After(time.Time{}) {
+	mustExec("insert into agg_run (started_at, status) values (CURRENT_TIMESTAMP, 'running')")
+	if v := LastRun(context.Background()); !v.Equal(time.Time{}) {
+		t.Errorf("Runs in progress should not count, got %v", v)
+	}
+	mustExec("insert into agg_run (started_at, finished_at, status, mode) values (CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'succeeded', 'user')")
+	if v := LastRun(context.Background()); !v.Equal(time.Time{}) {
+		t.Errorf("Single user runs should not count, got %v", v)
+	}
+	mustExec("insert into agg_run (started_at, finished_at, status) values (CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'succeeded')")
+	if v := LastRun(context.Background()); !v.After(time.Time{}) {
 		t.Errorf("Time should have been greater than zero value, got %v", v)
 	}
 }
 
+func TestRuns(t *testing.T) {
+	mustExec("delete from agg_run")
+	if runs := Runs(context.Background(), 10); runs == nil || len(runs) != 0 {
+		t.Fatalf("expected an empty list, got %v", runs)
+	}
+	var id int64
+	if err := db.QueryRow("insert into agg_run (started_at, finished_at, status, users_updated) values (CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'failed', 3) returning id").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	mustExec("insert into agg_run_error (run_id, login, message, created_at) values ($1, 'bob', 'boom', CURRENT_TIMESTAMP)", id)
+
+	runs := Runs(context.Background(), 10)
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(runs))
+	}
+	if runs[0].Status != "failed" || runs[0].UsersUpdated != 3 || runs[0].ErrorCount != 1 {
+		t.Errorf("unexpected run %+v", runs[0])
+	}
+
+	run, err := Run(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Errors) != 1 || run.Errors[0].Login != "bob" {
+		t.Errorf("unexpected errors %+v", run.Errors)
+	}
+}
+
 func TestHideUser(t *testing.T) {
 	mustExec("insert into agg_user (login, company, hide) values ('bob', '', false) on conflict do nothing")
-	if err := HideUser(true, "bob"); err != nil {
+	if err := HideUser(context.Background(), true, "bob"); err != nil {
 		t.Fatal(err)
 	}
-	user, err := Profile("bob")
+	user, err := Profile(context.Background(), "bob")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -51,10 +109,10 @@ func TestHideUser(t *testing.T) {
 		t.Fatal("expected hidden, was not")
 	}
 
-	if err = HideUser(false, "bob"); err != nil {
+	if err = HideUser(context.Background(), false, "bob"); err != nil {
 		t.Fatal(err)
 	}
-	user, err = Profile("bob")
+	user, err = Profile(context.Background(), "bob")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -63,8 +121,57 @@ func TestHideUser(t *testing.T) {
 	}
 }
 
+func TestOptOut(t *testing.T) {
+	mustExec("insert into agg_user (login, company, hide) values ('carol', '', false) on conflict do nothing")
+	mustExec("insert into agg_opt_in (login, requested_at) values ('carol', now()) on conflict do nothing")
+	if err := SetOptOut(context.Background(), "carol", true, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := Profile(context.Background(), "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.User.Hide || !user.User.OptedOut {
+		t.Fatal("expected opted out and hidden", user.User.Hide, user.User.OptedOut)
+	}
+	if optedIn, _ := queries.IsOptedIn(context.Background(), "carol"); optedIn {
+		t.Error("expected the opt in to be dropped")
+	}
+	// opt ins match the login in any case
+	mustExec("insert into agg_opt_in (login, requested_at) values ('Dave', now()) on conflict do nothing")
+	if optedIn, _ := queries.IsOptedIn(context.Background(), "dave"); !optedIn {
+		t.Error("expected Dave to be opted in")
+	}
+	if err = SetOptOut(context.Background(), "dave", true, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if optedIn, _ := queries.IsOptedIn(context.Background(), "Dave"); optedIn {
+		t.Error("expected Dave's opt in to be dropped")
+	}
+	if err = SetOptOut(context.Background(), "dave", false, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	// opting out again, in any case, is harmless
+	if err = SetOptOut(context.Background(), "Carol", true, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = SetOptOut(context.Background(), "carol", false, "carol"); err != nil {
+		t.Fatal(err)
+	}
+	user, err = Profile(context.Background(), "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.User.Hide || user.User.OptedOut {
+		t.Fatal("expected shown and not opted out", user.User.Hide, user.User.OptedOut)
+	}
+}
+
+var firstPage = PageRequest{Limit: DefaultPageSize}
+
 func TestPopularDevs(t *testing.T) {
-	result := PopularDevs("User", "company", "")
+	result := PopularDevs(context.Background(), "User", "company", "", firstPage).Items
 	if len(result) != 0 {
 		t.Error(len(result))
 	}
@@ -85,13 +192,13 @@ func TestPopularDevsCompanyFilter(t *testing.T) {
 		VALUES ($1, 'repo', false, 5, 1, 'Go')
 	`, login)
 
-	if got := PopularDevs("User", "", ""); len(got) != 1 {
+	if got := PopularDevs(context.Background(), "User", "", "", firstPage).Items; len(got) != 1 {
 		t.Fatalf("expected 1 dev without company filter, got %d", len(got))
 	}
-	if got := PopularDevs("User", "acme", ""); len(got) != 1 {
+	if got := PopularDevs(context.Background(), "User", "acme", "", firstPage).Items; len(got) != 1 {
 		t.Fatalf("expected 1 dev with matching company filter, got %d", len(got))
 	}
-	if got := PopularDevs("User", "nonexistent", ""); len(got) != 0 {
+	if got := PopularDevs(context.Background(), "User", "nonexistent", "", firstPage).Items; len(got) != 0 {
 		t.Fatalf("expected 0 devs with non-matching filter, got %d", len(got))
 	}
 }
@@ -132,7 +239,7 @@ func TestPopularDevsSorting(t *testing.T) {
 
 	t.Run("SortByStars", func(t *testing.T) {
 		// Default sorting by stars (descending)
-		got := PopularDevs("User", "", "stars")
+		got := PopularDevs(context.Background(), "User", "", "stars", firstPage).Items
 		if len(got) != 3 {
 			t.Fatalf("expected 3 devs, got %d", len(got))
 		}
@@ -150,7 +257,7 @@ func TestPopularDevsSorting(t *testing.T) {
 
 	t.Run("SortByStarsDefault", func(t *testing.T) {
 		// Empty string defaults to stars
-		got := PopularDevs("User", "", "")
+		got := PopularDevs(context.Background(), "User", "", "", firstPage).Items
 		if len(got) != 3 {
 			t.Fatalf("expected 3 devs, got %d", len(got))
 		}
@@ -167,7 +274,7 @@ func TestPopularDevsSorting(t *testing.T) {
 	})
 
 	t.Run("SortByForks", func(t *testing.T) {
-		got := PopularDevs("User", "", "forks")
+		got := PopularDevs(context.Background(), "User", "", "forks", firstPage).Items
 		if len(got) != 3 {
 			t.Fatalf("expected 3 devs, got %d", len(got))
 		}
@@ -184,7 +291,7 @@ func TestPopularDevsSorting(t *testing.T) {
 	})
 
 	t.Run("SortByFollowers", func(t *testing.T) {
-		got := PopularDevs("User", "", "followers")
+		got := PopularDevs(context.Background(), "User", "", "followers", firstPage).Items
 		if len(got) != 3 {
 			t.Fatalf("expected 3 devs, got %d", len(got))
 		}
@@ -201,7 +308,7 @@ func TestPopularDevsSorting(t *testing.T) {
 	})
 
 	t.Run("SortByPublicRepos", func(t *testing.T) {
-		got := PopularDevs("User", "", "public_repos")
+		got := PopularDevs(context.Background(), "User", "", "public_repos", firstPage).Items
 		if len(got) != 3 {
 			t.Fatalf("expected 3 devs, got %d", len(got))
 		}
@@ -218,7 +325,7 @@ func TestPopularDevsSorting(t *testing.T) {
 	})
 
 	t.Run("InvalidSortDefaultsToStars", func(t *testing.T) {
-		got := PopularDevs("User", "", "invalid_sort")
+		got := PopularDevs(context.Background(), "User", "", "invalid_sort", firstPage).Items
 		if len(got) != 3 {
 			t.Fatalf("expected 3 devs, got %d", len(got))
 		}
@@ -229,6 +336,284 @@ func TestPopularDevsSorting(t *testing.T) {
 	})
 }
 
+func TestPagination(t *testing.T) {
+	resetTables(t)
+	for i := 0; i < 5; i++ {
+		login := fmt.Sprintf("dev%v", i)
+		mustExec(`INSERT INTO agg_user (login, company, hide, type) VALUES ($1, '', false, 'User')`, login)
+		mustExec(`INSERT INTO agg_repo (owner, name, fork, stargazers_count, forks_count, language)
+			VALUES ($1, 'repo', false, $2, 0, 'Go')`, login, 10-i)
+	}
+
+	first := PopularDevs(context.Background(), "User", "", "", PageRequest{Limit: 2})
+	if first.Total != 5 || len(first.Items) != 2 || first.Items[0].Login != "dev0" || first.Prev != "" || first.Next == "" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	page, err := NewPageRequest(first.Next, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := PopularDevs(context.Background(), "User", "", "", page)
+	if len(second.Items) != 2 || second.Items[0].Login != "dev2" || second.Prev == "" || second.Next == "" {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+	page, _ = NewPageRequest(second.Next, 2)
+	last := PopularDevs(context.Background(), "User", "", "", page)
+	if len(last.Items) != 1 || last.Items[0].Login != "dev4" || last.Next != "" {
+		t.Fatalf("unexpected last page %+v", last)
+	}
+	page, _ = NewPageRequest(second.Prev, 2)
+	if again := PopularDevs(context.Background(), "User", "", "", page); again.Items[0].Login != "dev0" {
+		t.Fatalf("expected prev to go back to the first page, got %+v", again)
+	}
+
+	langs := Language(context.Background(), "go", PageRequest{Limit: 3})
+	if langs.Total != 5 || len(langs.Items) != 3 || langs.Items[0].Owner != "dev0" || langs.Next == "" {
+		t.Fatalf("unexpected language page %+v", langs)
+	}
+	page, _ = NewPageRequest(langs.Next, 3)
+	if langs = Language(context.Background(), "Go", page); langs.Total != 5 || len(langs.Items) != 2 || langs.Items[0].Owner != "dev3" {
+		t.Fatalf("unexpected language page %+v", langs)
+	}
+	if past := Language(context.Background(), "go", PageRequest{Offset: 9, Limit: 3}); past.Total != 5 || len(past.Items) != 0 || past.Next != "" {
+		t.Fatalf("unexpected language page past the end %+v", past)
+	}
+	if past := PopularDevs(context.Background(), "User", "", "", PageRequest{Offset: 10, Limit: 2}); past.Total != 5 || len(past.Items) != 0 {
+		t.Fatalf("unexpected page past the end %+v", past)
+	}
+	repos := SearchRepos(context.Background(), "language:go", PageRequest{Offset: 4, Limit: 2})
+	if repos.Total != 5 || len(repos.Items) != 1 || repos.Next != "" || repos.Prev == "" {
+		t.Fatalf("unexpected repo page %+v", repos)
+	}
+	if past := SearchRepos(context.Background(), "language:go", PageRequest{Offset: 6, Limit: 2}); past.Total != 5 || len(past.Items) != 0 {
+		t.Fatalf("unexpected repo page past the end %+v", past)
+	}
+	if past := SearchUsers(context.Background(), "language:go", PageRequest{Offset: 6, Limit: 2}); past.Total != 5 || len(past.Items) != 0 {
+		t.Fatalf("unexpected user page past the end %+v", past)
+	}
+}
+
+func TestLocationOverride(t *testing.T) {
+	resetTables(t)
+	mustExec(`
+		INSERT INTO agg_user (login, company, hide, type, location)
+		VALUES ('faraway', '', false, 'User', 'St. Louis Park, MN')
+	`)
+	mustExec(`
+		INSERT INTO agg_repo (owner, name, fork, stargazers_count, forks_count, language)
+		VALUES ('faraway', 'repo', false, 5, 1, 'Go')
+	`)
+	if got := PopularDevs(context.Background(), "User", "", "", firstPage).Items; len(got) != 1 {
+		t.Fatalf("expected 1 dev before the override, got %d", len(got))
+	}
+
+	if err := SetLocationOverride(context.Background(), "faraway", false, "not in St. Louis", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if got := PopularDevs(context.Background(), "User", "", "", firstPage).Items; len(got) != 0 {
+		t.Fatalf("expected excluded dev to drop out, got %d", len(got))
+	}
+	if got := LocationOverrides(context.Background()); len(got) != 1 || got[0].Include || got[0].CreatedBy != "admin" {
+		t.Fatalf("unexpected overrides %+v", got)
+	}
+
+	if err := DeleteLocationOverride(context.Background(), "faraway"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteLocationOverride(context.Background(), "faraway"); err == nil {
+		t.Fatal("expected an error deleting a missing override")
+	}
+}
+
+func TestOrgRegistry(t *testing.T) {
+	var seeded int
+	if err := db.QueryRow("select count(*) from agg_org_registry").Scan(&seeded); err != nil {
+		t.Fatal(err)
+	}
+	if seeded == 0 {
+		t.Fatal("expected the registry to be seeded from orgs.txt")
+	}
+
+	if err := AddOrg(context.Background(), "new-org", "local company", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddOrg(context.Background(), "new-org", "again", "admin"); !errors.Is(err, ErrOrgRegistered) {
+		t.Fatal("expected an error registering an org twice", err)
+	}
+	if err := AnnotateOrg(context.Background(), "new-org", "local startup"); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, org := range Orgs(context.Background()) {
+		if org.Login == "new-org" {
+			found = true
+			if org.Reason != "local startup" || org.AddedBy != "admin" {
+				t.Errorf("unexpected org %+v", org)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("expected new-org in the registry")
+	}
+	if err := RemoveOrg(context.Background(), "new-org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveOrg(context.Background(), "new-org"); err == nil {
+		t.Fatal("expected an error removing a missing org")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	resetTables(t)
+	var first, second int64
+	if err := db.QueryRow("insert into agg_run (started_at, status) values (now() - interval '1 day', 'succeeded') returning id").Scan(&first); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("insert into agg_run (started_at, status) values (now(), 'succeeded') returning id").Scan(&second); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(`insert into agg_user_snapshot (run_id, login, taken_at, followers, public_repos) values
+		($1, 'bob', now() - interval '1 day', 10, 2), ($2, 'bob', now(), 12, 2)`, first, second)
+	mustExec(`insert into agg_repo_snapshot (run_id, owner, name, taken_at, stargazers_count, forks_count) values
+		($1, 'bob', 'tool', now() - interval '1 day', 5, 1), ($1, 'bob', 'lib', now() - interval '1 day', 3, 0),
+		($2, 'bob', 'tool', now(), 9, 1), ($2, 'bob', 'lib', now(), 3, 1)`, first, second)
+
+	repo := RepoHistory(context.Background(), "bob", "tool", time.Now().AddDate(0, 0, -7))
+	if len(repo) != 2 || repo[0].StargazersCount != 5 || repo[1].StargazersCount != 9 {
+		t.Fatalf("unexpected repo history %+v", repo)
+	}
+	user := UserHistory(context.Background(), "bob", time.Now().AddDate(0, 0, -7))
+	if len(user) != 2 || user[0].Stars != 8 || user[1].Stars != 12 || user[1].Forks != 2 || user[1].Followers != 12 {
+		t.Fatalf("unexpected user history %+v", user)
+	}
+	if got := UserHistory(context.Background(), "bob", time.Now().Add(-time.Hour)); len(got) != 1 {
+		t.Fatalf("expected only the latest snapshot, got %+v", got)
+	}
+}
+
+func TestTrending(t *testing.T) {
+	resetTables(t)
+	mustExec("DELETE FROM agg_run")
+	var first, second int64
+	if err := db.QueryRow("insert into agg_run (started_at, status) values (now() - interval '3 days', 'succeeded') returning id").Scan(&first); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("insert into agg_run (started_at, status) values (now(), 'succeeded') returning id").Scan(&second); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(`INSERT INTO agg_user (login, company, hide, type, followers) VALUES
+		('alice', '', false, 'User', 30), ('bob', '', false, 'User', 20), ('carol', '', true, 'User', 90)`)
+	mustExec(`INSERT INTO agg_repo (owner, name, fork, stargazers_count, forks_count, language) VALUES
+		('alice', 'old', false, 500, 0, 'Go'), ('bob', 'new', false, 40, 0, 'Rust'), ('carol', 'hidden', false, 900, 0, 'Go')`)
+	mustExec(`insert into agg_user_snapshot (run_id, login, taken_at, followers, public_repos) values
+		($1, 'alice', now() - interval '3 days', 25, 1), ($2, 'alice', now(), 30, 1),
+		($1, 'bob', now() - interval '3 days', 20, 1), ($2, 'bob', now(), 20, 1),
+		($1, 'carol', now() - interval '3 days', 10, 1), ($2, 'carol', now(), 90, 1)`, first, second)
+	mustExec(`insert into agg_repo_snapshot (run_id, owner, name, taken_at, stargazers_count, forks_count) values
+		($1, 'alice', 'old', now() - interval '3 days', 495, 0), ($2, 'alice', 'old', now(), 500, 0),
+		($1, 'bob', 'new', now() - interval '3 days', 10, 0), ($2, 'bob', 'new', now(), 40, 0),
+		($1, 'carol', 'hidden', now() - interval '3 days', 100, 0), ($2, 'carol', 'hidden', now(), 900, 0)`, first, second)
+
+	repos := TrendingRepos(context.Background(), 7, "", "")
+	if len(repos) != 2 || repos[0].Name != "new" || repos[0].StarGrowth != 30 || repos[1].StarGrowth != 5 {
+		t.Fatalf("unexpected trending repos %+v", repos)
+	}
+	if repos = TrendingRepos(context.Background(), 7, "go", "User"); len(repos) != 1 || repos[0].Name != "old" {
+		t.Fatalf("expected only the Go repo, got %+v", repos)
+	}
+
+	devs := TrendingDevs(context.Background(), 7, "", "", "")
+	if len(devs) != 2 || devs[0].Login != "bob" || devs[0].StarGrowth != 30 {
+		t.Fatalf("unexpected trending devs by stars %+v", devs)
+	}
+	devs = TrendingDevs(context.Background(), 7, "", "", "followers")
+	if len(devs) != 2 || devs[0].Login != "alice" || devs[0].FollowerGrowth != 5 {
+		t.Fatalf("unexpected trending devs by followers %+v", devs)
+	}
+	if devs = TrendingDevs(context.Background(), 7, "rust", "", ""); len(devs) != 1 || devs[0].Login != "bob" {
+		t.Fatalf("expected only the Rust dev, got %+v", devs)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	resetTables(t)
+	mustExec(`INSERT INTO agg_user (login, name, company, bio, hide, type, followers) VALUES
+		('jakecoffman', 'Jake Coffman', '', 'Writes Go', false, 'User', 10),
+		('gopher', 'Gopher', 'Acme Widgets', '', false, 'User', 5),
+		('stlorg', 'STL Org', '', '', false, 'Organization', 1),
+		('widgeteer', 'Widgeteer', 'acme widgets', '', false, 'User', 2),
+		('ghost', 'Ghost', 'Acme Widgets', '', true, 'User', 50)`)
+	// neither hidden devs nor devs outside the region show up, nor their repos
+	mustExec(`INSERT INTO agg_user (login, name, company, hide, type, outside_region) VALUES
+		('faraway', 'Far Away', 'Acme Widgets', false, 'User', true)`)
+	mustExec(`INSERT INTO agg_repo (owner, name, description, fork, stargazers_count, language) VALUES
+		('jakecoffman', 'crud', 'OpenAPI routing for Go servers', false, 200, 'Go'),
+		('jakecoffman', 'cp', 'Chipmunk physics port', false, 50, 'Go'),
+		('gopher', 'routing-table', 'Static site generator', false, 5, 'Rust'),
+		('ghost', 'routing-ghost', 'Hidden routing', false, 500, 'Go'),
+		('faraway', 'routing-far', 'Faraway routing', false, 500, 'Rust')`)
+
+	if users := SearchUsers(context.Background(), "jakecofman", firstPage).Items; len(users) == 0 || users[0].Login != "jakecoffman" {
+		t.Errorf("expected a fuzzy login match, got %+v", users)
+	}
+	if users := SearchUsers(context.Background(), "acme", firstPage).Items; len(users) != 2 || users[0].Login != "gopher" {
+		t.Errorf("expected a company match, got %+v", users)
+	}
+	if users := SearchUsers(context.Background(), "type:organization", firstPage).Items; len(users) != 1 || users[0].Login != "stlorg" {
+		t.Errorf("expected only the org, got %+v", users)
+	}
+	if users := SearchUsers(context.Background(), "language:rust", firstPage).Items; len(users) != 1 || users[0].Login != "gopher" {
+		t.Errorf("expected only the Rust dev, got %+v", users)
+	}
+
+	if repos := SearchRepos(context.Background(), "routing", firstPage).Items; len(repos) != 2 || repos[0].Name != "crud" {
+		t.Errorf("expected the starred repo ranked first, got %+v", repos)
+	}
+	if repos := SearchRepos(context.Background(), "routing language:rust", firstPage).Items; len(repos) != 1 || repos[0].Name != "routing-table" {
+		t.Errorf("expected only the Rust repo, got %+v", repos)
+	}
+	if repos := SearchRepos(context.Background(), `"static site"`, firstPage).Items; len(repos) != 1 || repos[0].Name != "routing-table" {
+		t.Errorf("expected a phrase match, got %+v", repos)
+	}
+	if repos := SearchRepos(context.Background(), "user:jakecoffman", firstPage).Items; len(repos) != 2 {
+		t.Errorf("expected the user's repos, got %+v", repos)
+	}
+
+	results, err := Search(context.Background(), SearchQuery{Text: "routing", Language: "Go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Repos) != 1 || results.Repos[0].Name != "crud" {
+		t.Errorf("expected only the Go repo, got %+v", results.Repos)
+	}
+	// the language facet leaves out the language filter, counting the Rust repo too
+	if languages := results.Facets.Languages; len(languages) != 2 || languages[0].Count != 1 || languages[1].Count != 1 {
+		t.Errorf("unexpected language facets %+v", languages)
+	}
+	results, err = Search(context.Background(), SearchQuery{Type: "Organization"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Orgs) != 1 || len(results.Users) != 0 {
+		t.Errorf("expected only the org, got %+v", results)
+	}
+	if types := results.Facets.Types; len(types) != 2 || types[0].Value != "User" || types[0].Count != 3 {
+		t.Errorf("unexpected type facets %+v", types)
+	}
+
+	results, err = Search(context.Background(), SearchQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Users) != 3 || len(results.Orgs) != 1 {
+		t.Errorf("expected users and orgs listed apart, got %+v", results)
+	}
+	// spellings of the same company are counted together
+	if companies := results.Facets.Companies; len(companies) != 1 || companies[0].Count != 2 {
+		t.Errorf("unexpected company facets %+v", companies)
+	}
+}
+
 func resetTables(t *testing.T) {
 	t.Helper()
 	if _, err := db.Exec("DELETE FROM agg_repo"); err != nil {
@@ -244,3 +629,87 @@ func mustExec(query string, args ...any) {
 		panic(err)
 	}
 }
+
+func TestRoles(t *testing.T) {
+	ctx := context.Background()
+	if err := GrantRole(ctx, "dana", "overlord", "admin"); !errors.Is(err, ErrUnknownRole) {
+		t.Fatal("expected unknown role, got", err)
+	}
+	if err := GrantRole(ctx, "dana", "moderator", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	// granting again, in any case, is harmless
+	if err := GrantRole(ctx, "Dana", "moderator", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	roles, permissions, err := UserAccess(ctx, "DANA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roles, []string{"moderator"}) {
+		t.Error(roles)
+	}
+	if !reflect.DeepEqual(permissions, []string{"overrides.manage", "users.delete", "users.manage"}) {
+		t.Error(permissions)
+	}
+
+	var moderator *Role
+	for _, role := range Roles(ctx) {
+		if role.Name == "moderator" {
+			moderator = &role
+		}
+	}
+	if moderator == nil || len(moderator.Members) != 1 || moderator.Members[0].GrantedBy != "admin" {
+		t.Fatal("expected dana to be the moderator", moderator)
+	}
+
+	if err = RevokeRole(ctx, "dana", "moderator"); err != nil {
+		t.Fatal(err)
+	}
+	if err = RevokeRole(ctx, "dana", "moderator"); err == nil {
+		t.Error("expected revoking twice to fail")
+	}
+	if roles, _, _ = UserAccess(ctx, "dana"); len(roles) != 0 {
+		t.Error(roles)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	mustExec("insert into agg_user (login, company, hide) values ('erin', '', false) on conflict do nothing")
+	ctx := WithActor(context.Background(), "admin")
+	if err := HideUser(ctx, true, "erin"); err != nil {
+		t.Fatal(err)
+	}
+	// hiding again changes nothing so isn't logged
+	if err := HideUser(ctx, true, "erin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(context.Background(), "erin"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := Audit(ctx, AuditFilter{Target: "ERIN"}, firstPage)
+	if entries == nil || entries.Total != 2 {
+		t.Fatal("expected 2 entries", entries)
+	}
+	deleted, hidden := entries.Items[0], entries.Items[1]
+	if deleted.Action != ActionDeleteUser || deleted.Actor != SystemActor || string(deleted.After) != "null" {
+		t.Error(deleted.Action, deleted.Actor, string(deleted.After))
+	}
+	if !strings.Contains(string(deleted.Before), `"login": "erin"`) || strings.Contains(string(deleted.Before), "company") {
+		t.Error(string(deleted.Before))
+	}
+	if hidden.Action != ActionHideUser || hidden.Actor != "admin" ||
+		string(hidden.Before) != `{"hide": false}` || string(hidden.After) != `{"hide": true}` {
+		t.Error(hidden.Action, hidden.Actor, string(hidden.Before), string(hidden.After))
+	}
+
+	if entries = Audit(ctx, AuditFilter{Actor: "admin", Action: ActionDeleteUser}, firstPage); entries == nil || entries.Total != 0 {
+		t.Error("expected no deletes by admin", entries)
+	}
+
+	if _, err := db.Exec("delete from agg_audit"); err == nil {
+		t.Error("expected the audit log to be append-only")
+	}
+}
@@ -41,6 +41,11 @@ func Connect(cfg *config.Config) {
 	queries = sqlc.New(db)
 }
 
+// DB returns the connection pool opened by Connect.
+func DB() *sql.DB {
+	return db
+}
+
 func Migrate() {
 	if err := migrations.Migrate(db); err != nil {
 		log.Fatal("Could not migrate schema")
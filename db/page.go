@@ -0,0 +1,101 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	// DefaultPageSize is how many items a listing returns when no limit is given.
+	DefaultPageSize = 25
+	// MaxPageSize is the most items a listing returns at once.
+	MaxPageSize = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest is the part of a listing to return.
+type PageRequest struct {
+	Offset int
+	Limit  int
+}
+
+// cursor is what's behind the opaque next and prev strings. It's only an
+// offset for now, clients shouldn't rely on that.
+type cursor struct {
+	Offset int `json:"o"`
+}
+
+// NewPageRequest starts from the cursor of an earlier page, or from the
+// beginning if it's empty. The limit is clamped to MaxPageSize, and defaults to
+// DefaultPageSize when it's zero or less.
+func NewPageRequest(after string, limit int) (PageRequest, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	page := PageRequest{Limit: min(limit, MaxPageSize)}
+	if after == "" {
+		return page, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(after)
+	if err != nil {
+		return page, ErrInvalidCursor
+	}
+	var c cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.Offset < 0 {
+		return page, ErrInvalidCursor
+	}
+	page.Offset = c.Offset
+	return page, nil
+}
+
+func encodeCursor(offset int) string {
+	data, _ := json.Marshal(cursor{Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Page is part of a listing. Next and Prev are the cursors of the pages on
+// either side, empty at the ends.
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Total int    `json:"total"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// pageTotal is how many matches a listing has. Queries count their matches
+// with COUNT(*) OVER (), which every row carries since it's counted before the
+// limit. A page past the end has no rows to carry it, so count is run instead.
+func pageTotal[T any](rows []T, total func(T) int64, count func() (int64, error), req PageRequest) (int64, error) {
+	if len(rows) > 0 {
+		return total(rows[0]), nil
+	}
+	if req.Offset == 0 {
+		return 0, nil
+	}
+	return count()
+}
+
+// countedPage makes a page of rows with the total from pageTotal.
+func countedPage[T any](rows []T, total func(T) int64, count func() (int64, error), req PageRequest) (*Page[T], error) {
+	n, err := pageTotal(rows, total, count, req)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(rows, n, req), nil
+}
+
+func newPage[T any](items []T, total int64, req PageRequest) *Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	page := &Page[T]{Items: items, Total: int(total)}
+	if req.Offset+req.Limit < page.Total {
+		page.Next = encodeCursor(req.Offset + req.Limit)
+	}
+	if req.Offset > 0 {
+		page.Prev = encodeCursor(max(req.Offset-req.Limit, 0))
+	}
+	return page
+}
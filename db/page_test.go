@@ -0,0 +1,61 @@
+package db
+
+import "testing"
+
+func TestPageCursors(t *testing.T) {
+	page := newPage([]int{3, 4}, 7, PageRequest{Offset: 2, Limit: 2})
+	if page.Total != 7 || page.Next == "" || page.Prev == "" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	next, err := NewPageRequest(page.Next, 2)
+	if err != nil || next.Offset != 4 {
+		t.Errorf("expected next to start at 4, got %+v %v", next, err)
+	}
+	prev, err := NewPageRequest(page.Prev, 2)
+	if err != nil || prev.Offset != 0 {
+		t.Errorf("expected prev to start at 0, got %+v %v", prev, err)
+	}
+
+	last := newPage([]int{6}, 7, PageRequest{Offset: 6, Limit: 2})
+	if last.Next != "" {
+		t.Errorf("expected no next page, got %q", last.Next)
+	}
+	if first := newPage[int](nil, 0, PageRequest{Limit: 2}); first.Items == nil || first.Prev != "" || first.Next != "" {
+		t.Errorf("unexpected empty page %+v", first)
+	}
+}
+
+func TestCountedPage(t *testing.T) {
+	type row struct{ n, total int64 }
+	total := func(r row) int64 { return r.total }
+	counted := 0
+	count := func() (int64, error) {
+		counted++
+		return 5, nil
+	}
+	page, _ := countedPage([]row{{1, 5}, {2, 5}}, total, count, PageRequest{Limit: 2})
+	if page.Total != 5 || page.Next == "" || counted != 0 {
+		t.Errorf("expected the total from the rows, got %+v", page)
+	}
+	if first, _ := countedPage(nil, total, count, PageRequest{Limit: 2}); first.Total != 0 || counted != 0 {
+		t.Errorf("an empty first page has nothing to count, got %+v", first)
+	}
+	past, _ := countedPage(nil, total, count, PageRequest{Offset: 10, Limit: 2})
+	if past.Total != 5 || len(past.Items) != 0 || past.Next != "" || past.Prev == "" || counted != 1 {
+		t.Errorf("unexpected page past the end %+v", past)
+	}
+}
+
+func TestNewPageRequest(t *testing.T) {
+	if page, _ := NewPageRequest("", 0); page.Offset != 0 || page.Limit != DefaultPageSize {
+		t.Errorf("expected the default first page, got %+v", page)
+	}
+	if page, _ := NewPageRequest("", 1000); page.Limit != MaxPageSize {
+		t.Errorf("expected the limit clamped, got %+v", page)
+	}
+	for _, cursor := range []string{"25", "not base64!", encodeCursor(-5)} {
+		if _, err := NewPageRequest(cursor, 10); err != ErrInvalidCursor {
+			t.Errorf("%q: expected an invalid cursor, got %v", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// ErrUnknownRole is returned when granting a role that doesn't exist.
+var ErrUnknownRole = errors.New("unknown role")
+
+// Role is a role with what it permits and who it's granted to.
+type Role struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Permissions []string           `json:"permissions"`
+	Members     []sqlc.AggUserRole `json:"members"`
+}
+
+var Roles = func(ctx context.Context) []Role {
+	if queries == nil {
+		return nil
+	}
+	rows, err := queries.ListRoles(ctx)
+	if err != nil {
+		log.Println("ListRoles query failed:", err)
+		return nil
+	}
+	permissions, err := queries.RolePermissions(ctx)
+	if err != nil {
+		log.Println("RolePermissions query failed:", err)
+		return nil
+	}
+	members, err := queries.RoleMembers(ctx)
+	if err != nil {
+		log.Println("RoleMembers query failed:", err)
+		return nil
+	}
+
+	roles := make([]Role, len(rows))
+	byName := map[string]*Role{}
+	for i, row := range rows {
+		roles[i] = Role{
+			Name:        row.Name,
+			Description: row.Description,
+			Permissions: []string{},
+			Members:     []sqlc.AggUserRole{},
+		}
+		byName[row.Name] = &roles[i]
+	}
+	for _, p := range permissions {
+		if role, ok := byName[p.Role]; ok {
+			role.Permissions = append(role.Permissions, p.Permission)
+		}
+	}
+	for _, m := range members {
+		if role, ok := byName[m.Role]; ok {
+			role.Members = append(role.Members, m)
+		}
+	}
+	return roles
+}
+
+// UserAccess returns the roles granted to the user and the permissions they
+// add up to.
+var UserAccess = func(ctx context.Context, login string) (roles, permissions []string, err error) {
+	if queries == nil {
+		return nil, nil, fmt.Errorf("database not initialized")
+	}
+	roles, err = queries.UserRoles(ctx, login)
+	if err != nil {
+		log.Println("UserRoles query failed:", err)
+		return nil, nil, err
+	}
+	permissions, err = queries.UserPermissions(ctx, login)
+	if err != nil {
+		log.Println("UserPermissions query failed:", err)
+		return nil, nil, err
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return roles, permissions, nil
+}
+
+// GrantRole gives the user the role, granting it again does nothing.
+var GrantRole = func(ctx context.Context, login, role, grantedBy string) error {
+	return audited(ctx, func(q *sqlc.Queries) (*change, error) {
+		exists, err := q.RoleExists(ctx, role)
+		if err != nil {
+			log.Println("RoleExists query failed:", err)
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w %v", ErrUnknownRole, role)
+		}
+		affected, err := q.GrantRole(ctx, sqlc.GrantRoleParams{
+			Login:     login,
+			Role:      role,
+			GrantedBy: grantedBy,
+			GrantedAt: time.Now(),
+		})
+		if err != nil {
+			log.Println("GrantRole failed:", err)
+			return nil, err
+		}
+		if affected == 0 {
+			return nil, nil
+		}
+		return &change{action: ActionGrantRole, target: login, after: map[string]string{"role": role}}, nil
+	})
+}
+
+var RevokeRole = func(ctx context.Context, login, role string) error {
+	return audited(ctx, func(q *sqlc.Queries) (*change, error) {
+		affected, err := q.RevokeRole(ctx, sqlc.RevokeRoleParams{Lower: login, Role: role})
+		if err != nil {
+			log.Println("RevokeRole failed:", err)
+			return nil, err
+		}
+		if affected == 0 {
+			return nil, fmt.Errorf("%v doesn't have the %v role", login, role)
+		}
+		return &change{action: ActionRevokeRole, target: login, before: map[string]string{"role": role}}, nil
+	})
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// SearchQuery is a search box query split into its qualifiers and the free
+// text. The text keeps its "quoted phrases", OR and -exclusions since it's
+// handed to websearch_to_tsquery.
+type SearchQuery struct {
+	Text string
+	// Language is from language:go, matching repos in the language and devs with any.
+	Language string
+	// User is from user:login, matching repos the user owns.
+	User string
+	// Type is from type:organization, matching devs of the type.
+	Type string
+	// Company is from company:acme, matching devs who work there.
+	Company string
+}
+
+// ParseSearch splits the qualifiers out of a query such as
+// `"static site" language:go user:jakecoffman`. Words that look like
+// qualifiers but aren't known, such as a URL, are left in the text.
+func ParseSearch(q string) SearchQuery {
+	var query SearchQuery
+	var text []string
+	for _, token := range tokenize(q) {
+		key, value, found := strings.Cut(token, ":")
+		value = strings.Trim(value, `"`)
+		if !found || value == "" {
+			text = append(text, token)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "language", "lang":
+			query.Language = value
+		case "user", "owner":
+			query.User = value
+		case "type":
+			query.Type = value
+		case "company":
+			query.Company = value
+		default:
+			text = append(text, token)
+		}
+	}
+	query.Text = strings.Join(text, " ")
+	return query
+}
+
+// tokenize splits on spaces outside of double quotes, keeping the quotes.
+func tokenize(q string) []string {
+	var tokens []string
+	var token strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			token.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// SearchResults are the devs, split into users and orgs, and the repos matching
+// a search, with counts of how many match each filter value.
+type SearchResults struct {
+	Users  []sqlc.SearchUsersRow `json:"users"`
+	Orgs   []sqlc.SearchUsersRow `json:"orgs"`
+	Repos  []sqlc.SearchReposRow `json:"repos"`
+	Facets SearchFacets          `json:"facets"`
+}
+
+// SearchFacets count the matches by each value of a filter, leaving out that
+// filter itself so the counts show what choosing another value would give.
+// Languages count repos, types and companies count devs.
+type SearchFacets struct {
+	Languages []sqlc.SearchLanguageFacetsRow `json:"languages"`
+	Types     []sqlc.SearchTypeFacetsRow     `json:"types"`
+	Companies []sqlc.SearchCompanyFacetsRow  `json:"companies"`
+}
+
+// searchLimit is how many users, orgs and repos Search returns of each, it
+// isn't paged.
+const searchLimit = 50
+
+// Search finds the devs and repos matching the query, with facets.
+var Search = func(ctx context.Context, query SearchQuery) (*SearchResults, error) {
+	if queries == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	users, err := searchDevs(ctx, query, "User")
+	if err != nil {
+		return nil, err
+	}
+	orgs, err := searchDevs(ctx, query, "Organization")
+	if err != nil {
+		return nil, err
+	}
+	repos, err := searchRepos(ctx, query, PageRequest{Limit: searchLimit})
+	if err != nil {
+		return nil, err
+	}
+	results := &SearchResults{Users: users, Orgs: orgs, Repos: repos}
+
+	language := nullString(query.Language)
+	devType := nullString(query.Type)
+	company := nullString(query.Company)
+	results.Facets.Languages, err = queries.SearchLanguageFacets(ctx, sqlc.SearchLanguageFacetsParams{
+		Query:   query.Text,
+		Owner:   nullString(query.User),
+		DevType: devType,
+		Company: company,
+	})
+	if err != nil {
+		log.Println("SearchLanguageFacets query failed:", err)
+		return nil, err
+	}
+	results.Facets.Types, err = queries.SearchTypeFacets(ctx, sqlc.SearchTypeFacetsParams{
+		Query:    query.Text,
+		Company:  company,
+		Language: language,
+	})
+	if err != nil {
+		log.Println("SearchTypeFacets query failed:", err)
+		return nil, err
+	}
+	results.Facets.Companies, err = queries.SearchCompanyFacets(ctx, sqlc.SearchCompanyFacetsParams{
+		Query:    query.Text,
+		DevType:  devType,
+		Language: language,
+	})
+	if err != nil {
+		log.Println("SearchCompanyFacets query failed:", err)
+		return nil, err
+	}
+	if results.Facets.Languages == nil {
+		results.Facets.Languages = []sqlc.SearchLanguageFacetsRow{}
+	}
+	if results.Facets.Types == nil {
+		results.Facets.Types = []sqlc.SearchTypeFacetsRow{}
+	}
+	if results.Facets.Companies == nil {
+		results.Facets.Companies = []sqlc.SearchCompanyFacetsRow{}
+	}
+	return results, nil
+}
+
+// searchDevs finds the devs of the type, each type with its own limit so a
+// page of users doesn't crowd out the orgs. It finds none if the query is for
+// another type.
+func searchDevs(ctx context.Context, query SearchQuery, devType string) ([]sqlc.SearchUsersRow, error) {
+	if query.Type != "" && !strings.EqualFold(query.Type, devType) {
+		return []sqlc.SearchUsersRow{}, nil
+	}
+	query.Type = devType
+	devs, err := searchUsers(ctx, query, PageRequest{Limit: searchLimit})
+	if err != nil {
+		return nil, err
+	}
+	if devs == nil {
+		devs = []sqlc.SearchUsersRow{}
+	}
+	return devs, nil
+}
+
+func searchUsers(ctx context.Context, query SearchQuery, page PageRequest) ([]sqlc.SearchUsersRow, error) {
+	rows, err := queries.SearchUsers(ctx, sqlc.SearchUsersParams{
+		Query:    query.Text,
+		DevType:  nullString(query.Type),
+		Company:  nullString(query.Company),
+		Language: nullString(query.Language),
+		Limit:    int32(page.Limit),
+		Offset:   int32(page.Offset),
+	})
+	if err != nil {
+		log.Println("SearchUsers query failed:", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
+func searchRepos(ctx context.Context, query SearchQuery, page PageRequest) ([]sqlc.SearchReposRow, error) {
+	rows, err := queries.SearchRepos(ctx, sqlc.SearchReposParams{
+		Query:    query.Text,
+		Language: nullString(query.Language),
+		Owner:    nullString(query.User),
+		DevType:  nullString(query.Type),
+		Company:  nullString(query.Company),
+		Limit:    int32(page.Limit),
+		Offset:   int32(page.Offset),
+	})
+	if err != nil {
+		log.Println("SearchRepos query failed:", err)
+		return nil, err
+	}
+	if rows == nil {
+		rows = []sqlc.SearchReposRow{}
+	}
+	return rows, nil
+}
+
+// nullString is NULL for an empty filter, which the queries take as no filter.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,20 @@
+package db
+
+import "testing"
+
+func TestParseSearch(t *testing.T) {
+	for q, expected := range map[string]SearchQuery{
+		"static site":                        {Text: "static site"},
+		`"static site" language:go`:          {Text: `"static site"`, Language: "go"},
+		"lang:Go user:jakecoffman crud":      {Text: "crud", Language: "Go", User: "jakecoffman"},
+		`type:organization "st louis" -java`: {Text: `"st louis" -java`, Type: "organization"},
+		`language:"Jupyter Notebook"`:        {Language: "Jupyter Notebook"},
+		"company:acme go":                    {Text: "go", Company: "acme"},
+		"https://stldevs.com language:":      {Text: "https://stldevs.com language:"},
+		"  ":                                 {},
+	} {
+		if got := ParseSearch(q); got != expected {
+			t.Errorf("%q: expected %+v, got %+v", q, expected, got)
+		}
+	}
+}
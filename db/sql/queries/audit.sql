@@ -0,0 +1,21 @@
+-- name: InsertAudit :exec
+INSERT INTO agg_audit (actor, action, target, before, after, created_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ListAudit :many
+SELECT sqlc.embed(agg_audit), COUNT(*) OVER () AS total
+FROM agg_audit
+WHERE (sqlc.narg(actor)::text IS NULL OR LOWER(actor) = LOWER(sqlc.narg(actor)::text))
+  AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action)::text)
+  AND (sqlc.narg(target)::text IS NULL OR LOWER(target) = LOWER(sqlc.narg(target)::text))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR created_at >= sqlc.narg(since)::timestamptz)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountAudit :one
+SELECT COUNT(*)
+FROM agg_audit
+WHERE (sqlc.narg(actor)::text IS NULL OR LOWER(actor) = LOWER(sqlc.narg(actor)::text))
+  AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action)::text)
+  AND (sqlc.narg(target)::text IS NULL OR LOWER(target) = LOWER(sqlc.narg(target)::text))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR created_at >= sqlc.narg(since)::timestamptz);
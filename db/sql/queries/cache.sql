@@ -0,0 +1,14 @@
+-- name: GetHTTPCache :one
+SELECT url, etag, last_modified, link, body, updated_at
+FROM agg_http_cache
+WHERE url = $1;
+
+-- name: UpsertHTTPCache :exec
+INSERT INTO agg_http_cache (url, etag, last_modified, link, body, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (url) DO UPDATE
+SET etag = EXCLUDED.etag,
+    last_modified = EXCLUDED.last_modified,
+    link = EXCLUDED.link,
+    body = EXCLUDED.body,
+    updated_at = EXCLUDED.updated_at;
@@ -0,0 +1,23 @@
+-- name: AcquireLease :execrows
+INSERT INTO agg_lease (name, holder, acquired_at, heartbeat_at, expires_at)
+VALUES (sqlc.arg(name), sqlc.arg(holder), now(), now(), now() + make_interval(secs => sqlc.arg(ttl_seconds)::int))
+ON CONFLICT (name) DO UPDATE
+SET holder = EXCLUDED.holder,
+    acquired_at = EXCLUDED.acquired_at,
+    heartbeat_at = EXCLUDED.heartbeat_at,
+    expires_at = EXCLUDED.expires_at
+WHERE agg_lease.expires_at < now() OR agg_lease.holder = EXCLUDED.holder;
+
+-- name: RenewLease :execrows
+UPDATE agg_lease
+SET heartbeat_at = now(), expires_at = now() + make_interval(secs => sqlc.arg(ttl_seconds)::int)
+WHERE name = sqlc.arg(name) AND holder = sqlc.arg(holder);
+
+-- name: GetLease :one
+SELECT name, holder, acquired_at, heartbeat_at, expires_at
+FROM agg_lease
+WHERE name = $1 AND expires_at > now();
+
+-- name: ReleaseLease :exec
+DELETE FROM agg_lease
+WHERE name = $1 AND holder = $2;
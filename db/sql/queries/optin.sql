@@ -0,0 +1,19 @@
+-- name: InsertOptIn :exec
+INSERT INTO agg_opt_in (login, requested_at)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: OptInLogins :many
+SELECT login
+FROM agg_opt_in;
+
+-- name: IsOptedIn :one
+SELECT EXISTS (
+    SELECT 1
+    FROM agg_opt_in
+    WHERE LOWER(login) = LOWER($1)
+);
+
+-- name: DeleteOptIn :exec
+DELETE FROM agg_opt_in
+WHERE LOWER(login) = LOWER($1);
@@ -0,0 +1,19 @@
+-- name: InsertOptOut :exec
+INSERT INTO agg_opt_out (login, requested_by, requested_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteOptOut :execrows
+DELETE FROM agg_opt_out
+WHERE LOWER(login) = LOWER($1);
+
+-- name: OptOutLogins :many
+SELECT login
+FROM agg_opt_out;
+
+-- name: IsOptedOut :one
+SELECT EXISTS (
+    SELECT 1
+    FROM agg_opt_out
+    WHERE LOWER(login) = LOWER($1)
+);
@@ -0,0 +1,40 @@
+-- name: ListOrgs :many
+SELECT login, reason, added_by, created_at
+FROM agg_org_registry
+ORDER BY LOWER(login);
+
+-- name: OrgLogins :many
+SELECT login
+FROM agg_org_registry;
+
+-- name: GetOrg :one
+SELECT login, reason, added_by, created_at
+FROM agg_org_registry
+WHERE login = $1
+FOR UPDATE;
+
+-- name: InsertOrg :execrows
+INSERT INTO agg_org_registry (login, reason, added_by, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: UpdateOrgReason :execrows
+UPDATE agg_org_registry
+SET reason = $2
+WHERE login = $1;
+
+-- name: DeleteOrg :execrows
+DELETE FROM agg_org_registry
+WHERE login = $1;
+
+-- name: OrgListImported :one
+SELECT EXISTS (
+    SELECT 1
+    FROM agg_org_import
+    WHERE hash = $1
+);
+
+-- name: InsertOrgImport :execrows
+INSERT INTO agg_org_import (hash, path, imported_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,22 @@
+-- name: ListLocationOverrides :many
+SELECT login, include, reason, created_by, created_at
+FROM agg_location_override
+ORDER BY login;
+
+-- name: GetLocationOverride :one
+SELECT login, include, reason, created_by, created_at
+FROM agg_location_override
+WHERE login = $1;
+
+-- name: UpsertLocationOverride :exec
+INSERT INTO agg_location_override (login, include, reason, created_by, created_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (login) DO UPDATE
+SET include = EXCLUDED.include,
+    reason = EXCLUDED.reason,
+    created_by = EXCLUDED.created_by,
+    created_at = EXCLUDED.created_at;
+
+-- name: DeleteLocationOverride :execrows
+DELETE FROM agg_location_override
+WHERE login = $1;
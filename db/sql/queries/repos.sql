@@ -11,7 +11,16 @@ ORDER BY count DESC
 LIMIT 50;
 
 -- name: LanguageLeaders :many
-WITH ranked_repos AS (
+WITH leaders AS (
+    SELECT agg_repo.owner, SUM(agg_repo.stargazers_count) AS total_stars, COUNT(*) OVER () AS total
+    FROM agg_repo
+    JOIN agg_user ON agg_user.login = agg_repo.owner
+    WHERE LOWER(agg_repo.language) = LOWER(sqlc.arg(language))
+      AND agg_user.outside_region IS FALSE
+    GROUP BY agg_repo.owner
+    ORDER BY total_stars DESC, agg_repo.owner
+    LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset')
+), ranked_repos AS (
     SELECT
         r1.owner,
         r1.name,
@@ -20,15 +29,12 @@ WITH ranked_repos AS (
         r1.stargazers_count,
         r1.watchers_count,
         r1.fork,
-        (
-            SELECT SUM(stargazers_count)
-            FROM agg_repo
-            WHERE LOWER(language) = LOWER($1)
-              AND owner = r1.owner
-        ) AS total_stars,
+        leaders.total_stars,
+        leaders.total,
         ROW_NUMBER() OVER (PARTITION BY r1.owner ORDER BY r1.stargazers_count DESC) AS rownum
     FROM agg_repo AS r1
-    WHERE LOWER(r1.language) = LOWER($1)
+    JOIN leaders ON leaders.owner = r1.owner
+    WHERE LOWER(r1.language) = LOWER(sqlc.arg(language))
 )
 SELECT
     ranked_repos.owner,
@@ -41,12 +47,20 @@ SELECT
     ranked_repos.total_stars,
     ranked_repos.rownum,
     COALESCE(agg_user.name, '')::text AS display_name,
-    COALESCE(agg_user.type, '')::text AS type
+    COALESCE(agg_user.type, '')::text AS type,
+    ranked_repos.total
 FROM ranked_repos
 JOIN agg_user ON agg_user.login = ranked_repos.owner
 WHERE ranked_repos.rownum < 4
 ORDER BY ranked_repos.total_stars DESC, ranked_repos.owner, ranked_repos.stargazers_count DESC;
 
+-- name: CountLanguageLeaders :one
+SELECT COUNT(DISTINCT agg_repo.owner)
+FROM agg_repo
+JOIN agg_user ON agg_user.login = agg_repo.owner
+WHERE LOWER(agg_repo.language) = LOWER(sqlc.arg(language))
+  AND agg_user.outside_region IS FALSE;
+
 -- name: ReposForUser :many
 SELECT
     owner,
@@ -92,12 +106,52 @@ SELECT
     created_at,
     pushed_at,
     updated_at,
-    refreshed_at
+    refreshed_at,
+    COUNT(*) OVER () AS total
 FROM agg_repo
-WHERE LOWER(name) LIKE LOWER($1)
-   OR LOWER(description) LIKE LOWER($1)
-ORDER BY stargazers_count DESC
-LIMIT 50;
+WHERE (
+      sqlc.arg(query)::text = '' OR
+      search @@ websearch_to_tsquery('english', sqlc.arg(query)::text) OR
+      name % sqlc.arg(query)::text
+  )
+  AND (sqlc.narg(language)::text IS NULL OR LOWER(language) = LOWER(sqlc.narg(language)::text))
+  AND (sqlc.narg(owner)::text IS NULL OR LOWER(owner) = LOWER(sqlc.narg(owner)::text))
+  AND EXISTS (
+      SELECT 1 FROM agg_user
+      WHERE agg_user.login = agg_repo.owner
+        AND agg_user.hide IS FALSE
+        AND agg_user.outside_region IS FALSE
+        AND (sqlc.narg(dev_type)::text IS NULL OR LOWER(agg_user.type) = LOWER(sqlc.narg(dev_type)::text))
+        AND (sqlc.narg(company)::text IS NULL OR LOWER(agg_user.company) = LOWER(sqlc.narg(company)::text))
+  )
+ORDER BY
+    (
+        ts_rank(search, websearch_to_tsquery('english', sqlc.arg(query)::text)) +
+        similarity(name, sqlc.arg(query)::text)
+    ) * (1 + LN(1 + COALESCE(stargazers_count, 0))) DESC,
+    stargazers_count DESC NULLS LAST,
+    owner,
+    name
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountSearchRepos :one
+SELECT COUNT(*)
+FROM agg_repo
+WHERE (
+      sqlc.arg(query)::text = '' OR
+      search @@ websearch_to_tsquery('english', sqlc.arg(query)::text) OR
+      name % sqlc.arg(query)::text
+  )
+  AND (sqlc.narg(language)::text IS NULL OR LOWER(language) = LOWER(sqlc.narg(language)::text))
+  AND (sqlc.narg(owner)::text IS NULL OR LOWER(owner) = LOWER(sqlc.narg(owner)::text))
+  AND EXISTS (
+      SELECT 1 FROM agg_user
+      WHERE agg_user.login = agg_repo.owner
+        AND agg_user.hide IS FALSE
+        AND agg_user.outside_region IS FALSE
+        AND (sqlc.narg(dev_type)::text IS NULL OR LOWER(agg_user.type) = LOWER(sqlc.narg(dev_type)::text))
+        AND (sqlc.narg(company)::text IS NULL OR LOWER(agg_user.company) = LOWER(sqlc.narg(company)::text))
+  );
 
 -- name: DeleteReposByOwner :exec
 DELETE FROM agg_repo
@@ -157,3 +211,9 @@ SET
     updated_at = $18,
     refreshed_at = $19
 WHERE owner = $1 AND name = $2;
+
+-- name: ReposByOwner :many
+SELECT owner, name, description, language, homepage, forks_count,
+       open_issues_count, stargazers_count, watchers_count, fork, default_branch
+FROM agg_repo
+WHERE owner = $1;
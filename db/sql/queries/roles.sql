@@ -0,0 +1,44 @@
+-- name: ListRoles :many
+SELECT name, description
+FROM agg_role
+ORDER BY name;
+
+-- name: RolePermissions :many
+SELECT role, permission
+FROM agg_role_permission
+ORDER BY role, permission;
+
+-- name: RoleMembers :many
+SELECT login, role, granted_by, granted_at
+FROM agg_user_role
+ORDER BY role, login;
+
+-- name: RoleExists :one
+SELECT EXISTS (
+    SELECT 1
+    FROM agg_role
+    WHERE name = $1
+);
+
+-- name: UserRoles :many
+SELECT role
+FROM agg_user_role
+WHERE LOWER(login) = LOWER($1)
+ORDER BY role;
+
+-- name: UserPermissions :many
+SELECT DISTINCT rp.permission
+FROM agg_user_role ur
+JOIN agg_role_permission rp ON rp.role = ur.role
+WHERE LOWER(ur.login) = LOWER($1)
+ORDER BY rp.permission;
+
+-- name: GrantRole :execrows
+INSERT INTO agg_user_role (login, role, granted_by, granted_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: RevokeRole :execrows
+DELETE FROM agg_user_role
+WHERE LOWER(login) = LOWER($1)
+  AND role = $2;
@@ -0,0 +1,103 @@
+-- name: LastRun :one
+SELECT finished_at
+FROM agg_run
+WHERE status = 'succeeded' AND mode = 'full'
+ORDER BY finished_at DESC
+LIMIT 1;
+
+-- name: InsertRun :one
+INSERT INTO agg_run (started_at, status, mode)
+VALUES ($1, 'running', $2)
+RETURNING id;
+
+-- name: FinishRun :exec
+UPDATE agg_run
+SET
+    finished_at = $2,
+    status = $3,
+    users_discovered = $4,
+    users_updated = $5,
+    repos_inserted = $6,
+    repos_updated = $7,
+    repos_deleted = $8,
+    api_calls = $9
+WHERE id = $1;
+
+-- name: InsertRunError :exec
+INSERT INTO agg_run_error (run_id, login, message, created_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: ListRuns :many
+SELECT
+    agg_run.id,
+    agg_run.started_at,
+    agg_run.finished_at,
+    agg_run.status,
+    agg_run.users_discovered,
+    agg_run.users_updated,
+    agg_run.repos_inserted,
+    agg_run.repos_updated,
+    agg_run.repos_deleted,
+    agg_run.api_calls,
+    agg_run.mode,
+    (
+        SELECT COUNT(*)
+        FROM agg_run_error
+        WHERE agg_run_error.run_id = agg_run.id
+    ) AS error_count
+FROM agg_run
+ORDER BY agg_run.started_at DESC
+LIMIT $1;
+
+-- name: GetRun :one
+SELECT id, started_at, finished_at, status, users_discovered, users_updated,
+       repos_inserted, repos_updated, repos_deleted, api_calls, mode
+FROM agg_run
+WHERE id = $1;
+
+-- name: RunErrors :many
+SELECT id, run_id, login, message, created_at
+FROM agg_run_error
+WHERE run_id = $1
+ORDER BY created_at;
+
+-- name: LatestUnfinishedRun :one
+SELECT id, started_at, finished_at, status, users_discovered, users_updated,
+       repos_inserted, repos_updated, repos_deleted, api_calls, mode
+FROM agg_run
+WHERE mode = 'full'
+  AND status IN ('running', 'aborted')
+  AND started_at = (SELECT MAX(started_at) FROM agg_run WHERE mode = 'full')
+LIMIT 1;
+
+-- name: ReopenRun :exec
+UPDATE agg_run
+SET status = 'running', finished_at = NULL
+WHERE id = $1;
+
+-- name: AbortUnfinishedRuns :execrows
+UPDATE agg_run
+SET status = 'aborted', finished_at = $1
+WHERE status = 'running';
+
+-- name: InsertRunUser :exec
+INSERT INTO agg_run_user (run_id, login)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: CompleteRunUser :exec
+UPDATE agg_run_user
+SET done = TRUE
+WHERE run_id = $1 AND login = $2;
+
+-- name: PendingRunUsers :many
+SELECT login
+FROM agg_run_user
+WHERE run_id = $1 AND done = FALSE;
+
+-- name: CountRunUsers :one
+SELECT
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE done) AS done
+FROM agg_run_user
+WHERE run_id = $1;
@@ -0,0 +1,70 @@
+-- name: SearchCompanyFacets :many
+SELECT MIN(agg_user.company)::text AS value, COUNT(*)::int AS count
+FROM agg_user
+WHERE agg_user.hide IS FALSE
+  AND agg_user.outside_region IS FALSE
+  AND agg_user.company <> ''
+  AND (
+      sqlc.arg(query)::text = '' OR
+      agg_user.search @@ websearch_to_tsquery('english', sqlc.arg(query)::text) OR
+      agg_user.login % sqlc.arg(query)::text OR
+      agg_user.name % sqlc.arg(query)::text
+  )
+  AND (sqlc.narg(dev_type)::text IS NULL OR LOWER(agg_user.type) = LOWER(sqlc.narg(dev_type)::text))
+  AND (
+      sqlc.narg(language)::text IS NULL OR
+      EXISTS (
+          SELECT 1 FROM agg_repo
+          WHERE agg_repo.owner = agg_user.login
+            AND LOWER(agg_repo.language) = LOWER(sqlc.narg(language)::text)
+      )
+  )
+GROUP BY LOWER(agg_user.company)
+ORDER BY count DESC, value
+LIMIT 20;
+
+-- name: SearchLanguageFacets :many
+SELECT agg_repo.language::text AS value, COUNT(*)::int AS count
+FROM agg_repo
+WHERE agg_repo.language IS NOT NULL
+  AND agg_repo.language <> ''
+  AND (
+      sqlc.arg(query)::text = '' OR
+      agg_repo.search @@ websearch_to_tsquery('english', sqlc.arg(query)::text) OR
+      agg_repo.name % sqlc.arg(query)::text
+  )
+  AND (sqlc.narg(owner)::text IS NULL OR LOWER(agg_repo.owner) = LOWER(sqlc.narg(owner)::text))
+  AND EXISTS (
+      SELECT 1 FROM agg_user
+      WHERE agg_user.login = agg_repo.owner
+        AND agg_user.hide IS FALSE
+        AND agg_user.outside_region IS FALSE
+        AND (sqlc.narg(dev_type)::text IS NULL OR LOWER(agg_user.type) = LOWER(sqlc.narg(dev_type)::text))
+        AND (sqlc.narg(company)::text IS NULL OR LOWER(agg_user.company) = LOWER(sqlc.narg(company)::text))
+  )
+GROUP BY agg_repo.language
+ORDER BY count DESC, value
+LIMIT 20;
+
+-- name: SearchTypeFacets :many
+SELECT COALESCE(agg_user.type, '')::text AS value, COUNT(*)::int AS count
+FROM agg_user
+WHERE agg_user.hide IS FALSE
+  AND agg_user.outside_region IS FALSE
+  AND (
+      sqlc.arg(query)::text = '' OR
+      agg_user.search @@ websearch_to_tsquery('english', sqlc.arg(query)::text) OR
+      agg_user.login % sqlc.arg(query)::text OR
+      agg_user.name % sqlc.arg(query)::text
+  )
+  AND (sqlc.narg(company)::text IS NULL OR LOWER(agg_user.company) = LOWER(sqlc.narg(company)::text))
+  AND (
+      sqlc.narg(language)::text IS NULL OR
+      EXISTS (
+          SELECT 1 FROM agg_repo
+          WHERE agg_repo.owner = agg_user.login
+            AND LOWER(agg_repo.language) = LOWER(sqlc.narg(language)::text)
+      )
+  )
+GROUP BY agg_user.type
+ORDER BY count DESC, value;
@@ -0,0 +1,24 @@
+-- name: InsertSession :exec
+INSERT INTO agg_session (token_hash, login, identity, created_at, last_seen_at)
+VALUES ($1, $2, $3, $4, $4);
+
+-- name: GetSession :one
+SELECT token_hash, login, identity, created_at, last_seen_at
+FROM agg_session
+WHERE token_hash = sqlc.arg(token_hash)
+  AND created_at > sqlc.arg(created_after)
+  AND last_seen_at > sqlc.arg(seen_after);
+
+-- name: TouchSession :exec
+UPDATE agg_session
+SET last_seen_at = $2
+WHERE token_hash = $1;
+
+-- name: DeleteSession :exec
+DELETE FROM agg_session
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM agg_session
+WHERE created_at <= sqlc.arg(created_before)
+   OR last_seen_at <= sqlc.arg(seen_before);
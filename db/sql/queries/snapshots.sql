@@ -0,0 +1,72 @@
+-- name: InsertRepoSnapshot :exec
+INSERT INTO agg_repo_snapshot (
+    run_id, owner, name, taken_at,
+    stargazers_count, forks_count, watchers_count, open_issues_count
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (run_id, owner, name) DO UPDATE
+SET taken_at = EXCLUDED.taken_at,
+    stargazers_count = EXCLUDED.stargazers_count,
+    forks_count = EXCLUDED.forks_count,
+    watchers_count = EXCLUDED.watchers_count,
+    open_issues_count = EXCLUDED.open_issues_count;
+
+-- name: InsertUserSnapshot :exec
+INSERT INTO agg_user_snapshot (run_id, login, taken_at, followers, public_repos)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (run_id, login) DO UPDATE
+SET taken_at = EXCLUDED.taken_at,
+    followers = EXCLUDED.followers,
+    public_repos = EXCLUDED.public_repos;
+
+-- name: RepoHistory :many
+SELECT run_id, taken_at, stargazers_count, forks_count, watchers_count, open_issues_count
+FROM agg_repo_snapshot
+WHERE owner = $1 AND name = $2 AND taken_at >= $3
+ORDER BY taken_at;
+
+-- name: UserHistory :many
+SELECT
+    agg_user_snapshot.run_id,
+    agg_user_snapshot.taken_at,
+    agg_user_snapshot.followers,
+    agg_user_snapshot.public_repos,
+    COALESCE(SUM(agg_repo_snapshot.stargazers_count), 0)::int AS stars,
+    COALESCE(SUM(agg_repo_snapshot.forks_count), 0)::int AS forks
+FROM agg_user_snapshot
+LEFT JOIN agg_repo_snapshot
+    ON agg_repo_snapshot.run_id = agg_user_snapshot.run_id
+    AND agg_repo_snapshot.owner = agg_user_snapshot.login
+WHERE agg_user_snapshot.login = $1 AND agg_user_snapshot.taken_at >= $2
+GROUP BY agg_user_snapshot.run_id, agg_user_snapshot.taken_at,
+         agg_user_snapshot.followers, agg_user_snapshot.public_repos
+ORDER BY agg_user_snapshot.taken_at;
+
+-- name: DownsampleRepoSnapshots :execrows
+DELETE FROM agg_repo_snapshot
+USING (
+    SELECT run_id, owner, name, ROW_NUMBER() OVER (
+        PARTITION BY owner, name, date_trunc(sqlc.arg(bucket)::text, taken_at)
+        ORDER BY taken_at DESC
+    ) AS position
+    FROM agg_repo_snapshot
+    WHERE taken_at < sqlc.arg(before)
+) AS ranked
+WHERE agg_repo_snapshot.run_id = ranked.run_id
+    AND agg_repo_snapshot.owner = ranked.owner
+    AND agg_repo_snapshot.name = ranked.name
+    AND ranked.position > 1;
+
+-- name: DownsampleUserSnapshots :execrows
+DELETE FROM agg_user_snapshot
+USING (
+    SELECT run_id, login, ROW_NUMBER() OVER (
+        PARTITION BY login, date_trunc(sqlc.arg(bucket)::text, taken_at)
+        ORDER BY taken_at DESC
+    ) AS position
+    FROM agg_user_snapshot
+    WHERE taken_at < sqlc.arg(before)
+) AS ranked
+WHERE agg_user_snapshot.run_id = ranked.run_id
+    AND agg_user_snapshot.login = ranked.login
+    AND ranked.position > 1;
@@ -0,0 +1,83 @@
+-- name: TrendingDevs :many
+SELECT
+    agg_user.login,
+    COALESCE(agg_user.name, '')::text AS name,
+    agg_user.company,
+    COALESCE(agg_user.avatar_url, '')::text AS avatar_url,
+    COALESCE(agg_user.type, '')::text AS type,
+    COALESCE(agg_user.followers, 0)::int AS followers,
+    COALESCE(repo_growth.stars, 0)::int AS star_growth,
+    COALESCE(user_growth.last_followers - user_growth.first_followers, 0)::int AS follower_growth
+FROM agg_user
+LEFT JOIN (
+    SELECT
+        login,
+        (ARRAY_AGG(followers ORDER BY taken_at))[1] AS first_followers,
+        (ARRAY_AGG(followers ORDER BY taken_at DESC))[1] AS last_followers
+    FROM agg_user_snapshot
+    WHERE taken_at >= sqlc.arg(since)
+    GROUP BY login
+) AS user_growth ON user_growth.login = agg_user.login
+LEFT JOIN (
+    SELECT growth.owner, SUM(growth.last_stars - growth.first_stars) AS stars
+    FROM (
+        SELECT
+            owner,
+            name,
+            (ARRAY_AGG(stargazers_count ORDER BY taken_at))[1] AS first_stars,
+            (ARRAY_AGG(stargazers_count ORDER BY taken_at DESC))[1] AS last_stars
+        FROM agg_repo_snapshot
+        WHERE taken_at >= sqlc.arg(since)
+        GROUP BY owner, name
+    ) AS growth
+    JOIN agg_repo ON agg_repo.owner = growth.owner AND agg_repo.name = growth.name
+    WHERE sqlc.narg(language)::text IS NULL OR LOWER(agg_repo.language) = LOWER(sqlc.narg(language)::text)
+    GROUP BY growth.owner
+) AS repo_growth ON repo_growth.owner = agg_user.login
+WHERE agg_user.hide IS FALSE
+    AND agg_user.outside_region IS FALSE
+    AND (sqlc.narg(dev_type)::text IS NULL OR agg_user.type = sqlc.narg(dev_type)::text)
+    AND (sqlc.narg(language)::text IS NULL OR repo_growth.owner IS NOT NULL)
+    AND (
+        COALESCE(repo_growth.stars, 0) > 0 OR
+        COALESCE(user_growth.last_followers - user_growth.first_followers, 0) > 0
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(sort_by)::text = 'followers'
+        THEN COALESCE(user_growth.last_followers - user_growth.first_followers, 0)
+        ELSE COALESCE(repo_growth.stars, 0)
+    END DESC,
+    agg_user.followers DESC
+LIMIT 100;
+
+-- name: TrendingRepos :many
+SELECT
+    agg_repo.owner,
+    agg_repo.name,
+    COALESCE(agg_repo.description, '')::text AS description,
+    COALESCE(agg_repo.language, '')::text AS language,
+    COALESCE(agg_repo.stargazers_count, 0)::int AS stargazers_count,
+    COALESCE(agg_repo.forks_count, 0)::int AS forks_count,
+    (growth.last_stars - growth.first_stars)::int AS star_growth,
+    (growth.last_forks - growth.first_forks)::int AS fork_growth
+FROM (
+    SELECT
+        owner,
+        name,
+        (ARRAY_AGG(stargazers_count ORDER BY taken_at))[1] AS first_stars,
+        (ARRAY_AGG(stargazers_count ORDER BY taken_at DESC))[1] AS last_stars,
+        (ARRAY_AGG(forks_count ORDER BY taken_at))[1] AS first_forks,
+        (ARRAY_AGG(forks_count ORDER BY taken_at DESC))[1] AS last_forks
+    FROM agg_repo_snapshot
+    WHERE taken_at >= sqlc.arg(since)
+    GROUP BY owner, name
+) AS growth
+JOIN agg_repo ON agg_repo.owner = growth.owner AND agg_repo.name = growth.name
+JOIN agg_user ON agg_user.login = agg_repo.owner
+WHERE agg_user.hide IS FALSE
+    AND agg_user.outside_region IS FALSE
+    AND (sqlc.narg(language)::text IS NULL OR LOWER(agg_repo.language) = LOWER(sqlc.narg(language)::text))
+    AND (sqlc.narg(dev_type)::text IS NULL OR agg_user.type = sqlc.narg(dev_type)::text)
+    AND growth.last_stars > growth.first_stars
+ORDER BY star_growth DESC, agg_repo.stargazers_count DESC
+LIMIT 100;
@@ -8,7 +8,8 @@ SELECT
         COALESCE(agg_user.public_repos, 0)::int AS public_repos,
         repo.stars::int AS stars,
         repo.forks::int AS forks,
-        COALESCE(agg_user.type, '')::text AS type
+        COALESCE(agg_user.type, '')::text AS type,
+        COUNT(*) OVER () AS total
 FROM agg_user
 JOIN (
         SELECT owner, SUM(stargazers_count) AS stars, SUM(forks_count) AS forks
@@ -17,6 +18,7 @@ JOIN (
 ) AS repo ON repo.owner = agg_user.login
 WHERE agg_user.type = sqlc.arg(dev_type)
     AND agg_user.hide IS FALSE
+    AND agg_user.outside_region IS FALSE
     AND (
         sqlc.narg(company_pattern)::text IS NULL OR
         LOWER(agg_user.company) LIKE LOWER(sqlc.narg(company_pattern)::text)
@@ -26,9 +28,24 @@ ORDER BY
     CASE WHEN sqlc.arg(sort_by)::text = 'forks' THEN repo.forks END DESC,
     CASE WHEN sqlc.arg(sort_by)::text = 'followers' THEN agg_user.followers END DESC,
     CASE WHEN sqlc.arg(sort_by)::text = 'public_repos' THEN agg_user.public_repos END DESC,
-    repo.stars DESC
-LIMIT 100;
+    repo.stars DESC,
+    agg_user.login
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
 
+-- name: CountPopularDevs :one
+SELECT COUNT(*)
+FROM agg_user
+JOIN (
+        SELECT DISTINCT owner
+        FROM agg_repo
+) AS repo ON repo.owner = agg_user.login
+WHERE agg_user.type = sqlc.arg(dev_type)
+    AND agg_user.hide IS FALSE
+    AND agg_user.outside_region IS FALSE
+    AND (
+        sqlc.narg(company_pattern)::text IS NULL OR
+        LOWER(agg_user.company) LIKE LOWER(sqlc.narg(company_pattern)::text)
+    );
 
 -- name: UpdateUser :execrows
 UPDATE agg_user
@@ -83,6 +100,16 @@ UPDATE agg_user
 SET hide = $1
 WHERE login = $2;
 
+-- name: SetUserOutsideRegion :exec
+UPDATE agg_user
+SET outside_region = $2
+WHERE login = $1;
+
+-- name: RegionUsers :many
+SELECT login
+FROM agg_user
+WHERE type = 'User' AND outside_region IS FALSE;
+
 -- name: DeleteUser :exec
 DELETE FROM agg_user
 WHERE login = $1;
@@ -108,6 +135,11 @@ SELECT
     agg_user.company,
     agg_user.hide,
     agg_user.is_admin,
+    agg_user.outside_region,
+    EXISTS (
+        SELECT 1 FROM agg_opt_out
+        WHERE LOWER(agg_opt_out.login) = LOWER(agg_user.login)
+    ) AS opted_out,
     COALESCE(repo.stars, 0)::int AS stars,
     COALESCE(repo.forks, 0)::int AS forks
 FROM agg_user
@@ -130,13 +162,77 @@ SELECT
     agg_user.hide,
     agg_user.is_admin,
     COALESCE(repo.stars, 0)::int AS stars,
-    COALESCE(repo.forks, 0)::int AS forks
+    COALESCE(repo.forks, 0)::int AS forks,
+    COUNT(*) OVER () AS total
 FROM agg_user
 LEFT JOIN (
     SELECT owner, SUM(stargazers_count) AS stars, SUM(forks_count) AS forks
     FROM agg_repo
     GROUP BY owner
 ) AS repo ON repo.owner = agg_user.login
-WHERE login LIKE $1
-ORDER BY stars DESC
-LIMIT 50;
+WHERE agg_user.hide IS FALSE
+  AND agg_user.outside_region IS FALSE
+  AND (
+      sqlc.arg(query)::text = '' OR
+      agg_user.search @@ websearch_to_tsquery('english', sqlc.arg(query)::text) OR
+      agg_user.login % sqlc.arg(query)::text OR
+      agg_user.name % sqlc.arg(query)::text
+  )
+  AND (sqlc.narg(dev_type)::text IS NULL OR LOWER(agg_user.type) = LOWER(sqlc.narg(dev_type)::text))
+  AND (sqlc.narg(company)::text IS NULL OR LOWER(agg_user.company) = LOWER(sqlc.narg(company)::text))
+  AND (
+      sqlc.narg(language)::text IS NULL OR
+      EXISTS (
+          SELECT 1 FROM agg_repo
+          WHERE agg_repo.owner = agg_user.login
+            AND LOWER(agg_repo.language) = LOWER(sqlc.narg(language)::text)
+      )
+  )
+ORDER BY
+    (
+        ts_rank(agg_user.search, websearch_to_tsquery('english', sqlc.arg(query)::text)) +
+        GREATEST(similarity(agg_user.login, sqlc.arg(query)::text), similarity(COALESCE(agg_user.name, ''), sqlc.arg(query)::text))
+    ) * (1 + LN(1 + COALESCE(repo.stars, 0))) DESC,
+    stars DESC,
+    agg_user.login
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountSearchUsers :one
+SELECT COUNT(*)
+FROM agg_user
+WHERE agg_user.hide IS FALSE
+  AND agg_user.outside_region IS FALSE
+  AND (
+      sqlc.arg(query)::text = '' OR
+      agg_user.search @@ websearch_to_tsquery('english', sqlc.arg(query)::text) OR
+      agg_user.login % sqlc.arg(query)::text OR
+      agg_user.name % sqlc.arg(query)::text
+  )
+  AND (sqlc.narg(dev_type)::text IS NULL OR LOWER(agg_user.type) = LOWER(sqlc.narg(dev_type)::text))
+  AND (sqlc.narg(company)::text IS NULL OR LOWER(agg_user.company) = LOWER(sqlc.narg(company)::text))
+  AND (
+      sqlc.narg(language)::text IS NULL OR
+      EXISTS (
+          SELECT 1 FROM agg_repo
+          WHERE agg_repo.owner = agg_user.login
+            AND LOWER(agg_repo.language) = LOWER(sqlc.narg(language)::text)
+      )
+  );
+
+-- name: StaleUsers :many
+SELECT login
+FROM agg_user
+WHERE refreshed_at IS NULL OR refreshed_at < $1
+ORDER BY refreshed_at NULLS FIRST;
+
+-- name: UserByLogin :one
+SELECT login, email, location, hireable, blog, bio, followers, following,
+       public_repos, public_gists, avatar_url, type, name, company, outside_region
+FROM agg_user
+WHERE login = $1;
+
+-- name: UserHidden :one
+SELECT hide
+FROM agg_user
+WHERE login = $1
+FOR UPDATE;
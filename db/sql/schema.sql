@@ -1,3 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TABLE IF NOT EXISTS agg_meta (
     created_at TIMESTAMPTZ NOT NULL
 );
@@ -22,9 +24,20 @@ CREATE TABLE IF NOT EXISTS agg_user (
     hide BOOLEAN NOT NULL DEFAULT FALSE,
     is_admin BOOLEAN NOT NULL DEFAULT FALSE,
     refreshed_at TIMESTAMPTZ,
-    company TEXT NOT NULL DEFAULT ''
+    company TEXT NOT NULL DEFAULT '',
+    outside_region BOOLEAN NOT NULL DEFAULT FALSE,
+    search TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(login, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(company, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(bio, '')), 'C')
+    ) STORED
 );
 
+CREATE INDEX IF NOT EXISTS agg_user_search ON agg_user USING GIN (search);
+CREATE INDEX IF NOT EXISTS agg_user_login_trgm ON agg_user USING GIN (login gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS agg_user_name_trgm ON agg_user USING GIN (name gin_trgm_ops);
+
 CREATE TABLE IF NOT EXISTS agg_repo (
     owner VARCHAR(255) NOT NULL,
     name VARCHAR(255) NOT NULL,
@@ -45,9 +58,177 @@ CREATE TABLE IF NOT EXISTS agg_repo (
     pushed_at TIMESTAMPTZ,
     updated_at TIMESTAMPTZ,
     refreshed_at TIMESTAMPTZ,
+    search TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(language, '')), 'C')
+    ) STORED,
     PRIMARY KEY (owner, name)
 );
 
+CREATE INDEX IF NOT EXISTS agg_repo_search ON agg_repo USING GIN (search);
+CREATE INDEX IF NOT EXISTS agg_repo_name_trgm ON agg_repo USING GIN (name gin_trgm_ops);
+
 CREATE TABLE IF NOT EXISTS migrations (
     name VARCHAR(255) PRIMARY KEY
 );
+
+CREATE TABLE IF NOT EXISTS agg_http_cache (
+    url TEXT PRIMARY KEY,
+    etag TEXT NOT NULL DEFAULT '',
+    last_modified TEXT NOT NULL DEFAULT '',
+    link TEXT NOT NULL DEFAULT '',
+    body BYTEA NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agg_run (
+    id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    status VARCHAR(16) NOT NULL,
+    users_discovered INTEGER NOT NULL DEFAULT 0,
+    users_updated INTEGER NOT NULL DEFAULT 0,
+    repos_inserted INTEGER NOT NULL DEFAULT 0,
+    repos_updated INTEGER NOT NULL DEFAULT 0,
+    repos_deleted INTEGER NOT NULL DEFAULT 0,
+    api_calls INTEGER NOT NULL DEFAULT 0,
+    mode VARCHAR(16) NOT NULL DEFAULT 'full'
+);
+
+CREATE TABLE IF NOT EXISTS agg_run_error (
+    id BIGSERIAL PRIMARY KEY,
+    run_id BIGINT NOT NULL REFERENCES agg_run (id) ON DELETE CASCADE,
+    login VARCHAR(255) NOT NULL DEFAULT '',
+    message TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agg_run_user (
+    run_id BIGINT NOT NULL REFERENCES agg_run (id) ON DELETE CASCADE,
+    login VARCHAR(255) NOT NULL,
+    done BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (run_id, login)
+);
+
+CREATE TABLE IF NOT EXISTS agg_location_override (
+    login VARCHAR(255) PRIMARY KEY,
+    include BOOLEAN NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agg_org_registry (
+    login VARCHAR(255) PRIMARY KEY,
+    reason TEXT NOT NULL DEFAULT '',
+    added_by VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS agg_opt_in (
+    login VARCHAR(255) PRIMARY KEY,
+    requested_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agg_lease (
+    name VARCHAR(255) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL,
+    heartbeat_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS agg_repo_snapshot (
+    run_id BIGINT NOT NULL REFERENCES agg_run (id) ON DELETE CASCADE,
+    owner VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL,
+    stargazers_count INTEGER NOT NULL DEFAULT 0,
+    forks_count INTEGER NOT NULL DEFAULT 0,
+    watchers_count INTEGER NOT NULL DEFAULT 0,
+    open_issues_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (run_id, owner, name)
+);
+
+CREATE INDEX IF NOT EXISTS agg_repo_snapshot_repo ON agg_repo_snapshot (owner, name, taken_at);
+
+CREATE TABLE IF NOT EXISTS agg_user_snapshot (
+    run_id BIGINT NOT NULL REFERENCES agg_run (id) ON DELETE CASCADE,
+    login VARCHAR(255) NOT NULL,
+    taken_at TIMESTAMPTZ NOT NULL,
+    followers INTEGER NOT NULL DEFAULT 0,
+    public_repos INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (run_id, login)
+);
+
+CREATE INDEX IF NOT EXISTS agg_user_snapshot_login ON agg_user_snapshot (login, taken_at);
+
+CREATE TABLE IF NOT EXISTS agg_opt_out (
+    login VARCHAR(255) PRIMARY KEY,
+    requested_by VARCHAR(255) NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS agg_opt_out_lower_login ON agg_opt_out (LOWER(login));
+
+CREATE TABLE IF NOT EXISTS agg_session (
+    token_hash BYTEA PRIMARY KEY,
+    login VARCHAR(255) NOT NULL,
+    identity JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS agg_session_login ON agg_session (login);
+
+CREATE TABLE IF NOT EXISTS agg_role (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS agg_role_permission (
+    role VARCHAR(64) NOT NULL REFERENCES agg_role (name) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS agg_user_role (
+    login VARCHAR(255) NOT NULL,
+    role VARCHAR(64) NOT NULL REFERENCES agg_role (name) ON DELETE CASCADE,
+    granted_by VARCHAR(255) NOT NULL,
+    granted_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (login, role)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS agg_user_role_lower_login ON agg_user_role (LOWER(login), role);
+
+CREATE TABLE IF NOT EXISTS agg_audit (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(255) NOT NULL,
+    before JSONB NOT NULL DEFAULT 'null',
+    after JSONB NOT NULL DEFAULT 'null',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS agg_audit_target ON agg_audit (LOWER(target), id);
+
+CREATE INDEX IF NOT EXISTS agg_audit_actor ON agg_audit (LOWER(actor), id);
+
+CREATE OR REPLACE FUNCTION agg_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'agg_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER agg_audit_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON agg_audit
+    FOR EACH STATEMENT EXECUTE FUNCTION agg_audit_append_only();
+
+CREATE TABLE IF NOT EXISTS agg_org_import (
+    hash BYTEA PRIMARY KEY,
+    path TEXT NOT NULL,
+    imported_at TIMESTAMPTZ NOT NULL
+);
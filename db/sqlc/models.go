@@ -6,13 +6,73 @@ package sqlc
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
+type AggAudit struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type AggHttpCache struct {
+	Url          string    `json:"url"`
+	Etag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	Link         string    `json:"link"`
+	Body         []byte    `json:"body"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type AggLease struct {
+	Name        string    `json:"name"`
+	Holder      string    `json:"holder"`
+	AcquiredAt  time.Time `json:"acquired_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type AggLocationOverride struct {
+	Login     string    `json:"login"`
+	Include   bool      `json:"include"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type AggMetum struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type AggOptIn struct {
+	Login       string    `json:"login"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+type AggOptOut struct {
+	Login       string    `json:"login"`
+	RequestedBy string    `json:"requested_by"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+type AggOrgImport struct {
+	Hash       []byte    `json:"hash"`
+	Path       string    `json:"path"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+type AggOrgRegistry struct {
+	Login     string    `json:"login"`
+	Reason    string    `json:"reason"`
+	AddedBy   string    `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type AggRepo struct {
 	Owner            string         `json:"owner"`
 	Name             string         `json:"name"`
@@ -33,29 +93,104 @@ type AggRepo struct {
 	PushedAt         sql.NullTime   `json:"pushed_at"`
 	UpdatedAt        sql.NullTime   `json:"updated_at"`
 	RefreshedAt      sql.NullTime   `json:"refreshed_at"`
+	Search           interface{}    `json:"-"`
+}
+
+type AggRepoSnapshot struct {
+	RunID           int64     `json:"run_id"`
+	Owner           string    `json:"owner"`
+	Name            string    `json:"name"`
+	TakenAt         time.Time `json:"taken_at"`
+	StargazersCount int32     `json:"stargazers_count"`
+	ForksCount      int32     `json:"forks_count"`
+	WatchersCount   int32     `json:"watchers_count"`
+	OpenIssuesCount int32     `json:"open_issues_count"`
+}
+
+type AggRole struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type AggRolePermission struct {
+	Role       string `json:"role"`
+	Permission string `json:"permission"`
+}
+
+type AggRun struct {
+	ID              int64        `json:"id"`
+	StartedAt       time.Time    `json:"started_at"`
+	FinishedAt      sql.NullTime `json:"finished_at"`
+	Status          string       `json:"status"`
+	UsersDiscovered int32        `json:"users_discovered"`
+	UsersUpdated    int32        `json:"users_updated"`
+	ReposInserted   int32        `json:"repos_inserted"`
+	ReposUpdated    int32        `json:"repos_updated"`
+	ReposDeleted    int32        `json:"repos_deleted"`
+	ApiCalls        int32        `json:"api_calls"`
+	Mode            string       `json:"mode"`
+}
+
+type AggRunError struct {
+	ID        int64     `json:"id"`
+	RunID     int64     `json:"run_id"`
+	Login     string    `json:"login"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AggRunUser struct {
+	RunID int64  `json:"run_id"`
+	Login string `json:"login"`
+	Done  bool   `json:"done"`
+}
+
+type AggSession struct {
+	TokenHash  []byte          `json:"token_hash"`
+	Login      string          `json:"login"`
+	Identity   json.RawMessage `json:"identity"`
+	CreatedAt  time.Time       `json:"created_at"`
+	LastSeenAt time.Time       `json:"last_seen_at"`
 }
 
 type AggUser struct {
-	Login       string         `json:"login"`
-	Email       sql.NullString `json:"email"`
-	Location    sql.NullString `json:"location"`
-	Hireable    sql.NullBool   `json:"hireable"`
-	Blog        sql.NullString `json:"blog"`
-	Bio         sql.NullString `json:"bio"`
-	Followers   sql.NullInt32  `json:"followers"`
-	Following   sql.NullInt32  `json:"following"`
-	PublicRepos sql.NullInt32  `json:"public_repos"`
-	PublicGists sql.NullInt32  `json:"public_gists"`
-	AvatarUrl   sql.NullString `json:"avatar_url"`
-	DiskUsage   sql.NullInt32  `json:"disk_usage"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
-	UpdatedAt   sql.NullTime   `json:"updated_at"`
-	Type        sql.NullString `json:"type"`
-	Name        sql.NullString `json:"name"`
-	Hide        bool           `json:"hide"`
-	IsAdmin     bool           `json:"is_admin"`
-	RefreshedAt sql.NullTime   `json:"refreshed_at"`
-	Company     string         `json:"company"`
+	Login         string         `json:"login"`
+	Email         sql.NullString `json:"email"`
+	Location      sql.NullString `json:"location"`
+	Hireable      sql.NullBool   `json:"hireable"`
+	Blog          sql.NullString `json:"blog"`
+	Bio           sql.NullString `json:"bio"`
+	Followers     sql.NullInt32  `json:"followers"`
+	Following     sql.NullInt32  `json:"following"`
+	PublicRepos   sql.NullInt32  `json:"public_repos"`
+	PublicGists   sql.NullInt32  `json:"public_gists"`
+	AvatarUrl     sql.NullString `json:"avatar_url"`
+	DiskUsage     sql.NullInt32  `json:"disk_usage"`
+	CreatedAt     sql.NullTime   `json:"created_at"`
+	UpdatedAt     sql.NullTime   `json:"updated_at"`
+	Type          sql.NullString `json:"type"`
+	Name          sql.NullString `json:"name"`
+	Hide          bool           `json:"hide"`
+	IsAdmin       bool           `json:"is_admin"`
+	RefreshedAt   sql.NullTime   `json:"refreshed_at"`
+	Company       string         `json:"company"`
+	OutsideRegion bool           `json:"outside_region"`
+	Search        interface{}    `json:"-"`
+}
+
+type AggUserRole struct {
+	Login     string    `json:"login"`
+	Role      string    `json:"role"`
+	GrantedBy string    `json:"granted_by"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+type AggUserSnapshot struct {
+	RunID       int64     `json:"run_id"`
+	Login       string    `json:"login"`
+	TakenAt     time.Time `json:"taken_at"`
+	Followers   int32     `json:"followers"`
+	PublicRepos int32     `json:"public_repos"`
 }
 
 type Migration struct {
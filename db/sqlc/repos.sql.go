@@ -10,6 +10,62 @@ import (
 	"database/sql"
 )
 
+const countLanguageLeaders = `-- name: CountLanguageLeaders :one
+SELECT COUNT(DISTINCT agg_repo.owner)
+FROM agg_repo
+JOIN agg_user ON agg_user.login = agg_repo.owner
+WHERE LOWER(agg_repo.language) = LOWER($1)
+  AND agg_user.outside_region IS FALSE
+`
+
+func (q *Queries) CountLanguageLeaders(ctx context.Context, language string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countLanguageLeaders, language)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countSearchRepos = `-- name: CountSearchRepos :one
+SELECT COUNT(*)
+FROM agg_repo
+WHERE (
+      $1::text = '' OR
+      search @@ websearch_to_tsquery('english', $1::text) OR
+      name % $1::text
+  )
+  AND ($2::text IS NULL OR LOWER(language) = LOWER($2::text))
+  AND ($3::text IS NULL OR LOWER(owner) = LOWER($3::text))
+  AND EXISTS (
+      SELECT 1 FROM agg_user
+      WHERE agg_user.login = agg_repo.owner
+        AND agg_user.hide IS FALSE
+        AND agg_user.outside_region IS FALSE
+        AND ($4::text IS NULL OR LOWER(agg_user.type) = LOWER($4::text))
+        AND ($5::text IS NULL OR LOWER(agg_user.company) = LOWER($5::text))
+  )
+`
+
+type CountSearchReposParams struct {
+	Query    string         `json:"query"`
+	Language sql.NullString `json:"language"`
+	Owner    sql.NullString `json:"owner"`
+	DevType  sql.NullString `json:"dev_type"`
+	Company  sql.NullString `json:"company"`
+}
+
+func (q *Queries) CountSearchRepos(ctx context.Context, arg CountSearchReposParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSearchRepos,
+		arg.Query,
+		arg.Language,
+		arg.Owner,
+		arg.DevType,
+		arg.Company,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const deleteReposByOwner = `-- name: DeleteReposByOwner :exec
 DELETE FROM agg_repo
 WHERE owner = $1
@@ -114,7 +170,16 @@ func (q *Queries) InsertRepo(ctx context.Context, arg InsertRepoParams) error {
 }
 
 const languageLeaders = `-- name: LanguageLeaders :many
-WITH ranked_repos AS (
+WITH leaders AS (
+    SELECT agg_repo.owner, SUM(agg_repo.stargazers_count) AS total_stars, COUNT(*) OVER () AS total
+    FROM agg_repo
+    JOIN agg_user ON agg_user.login = agg_repo.owner
+    WHERE LOWER(agg_repo.language) = LOWER($1)
+      AND agg_user.outside_region IS FALSE
+    GROUP BY agg_repo.owner
+    ORDER BY total_stars DESC, agg_repo.owner
+    LIMIT $2 OFFSET $3
+), ranked_repos AS (
     SELECT
         r1.owner,
         r1.name,
@@ -123,14 +188,11 @@ WITH ranked_repos AS (
         r1.stargazers_count,
         r1.watchers_count,
         r1.fork,
-        (
-            SELECT SUM(stargazers_count)
-            FROM agg_repo
-            WHERE LOWER(language) = LOWER($1)
-              AND owner = r1.owner
-        ) AS total_stars,
+        leaders.total_stars,
+        leaders.total,
         ROW_NUMBER() OVER (PARTITION BY r1.owner ORDER BY r1.stargazers_count DESC) AS rownum
     FROM agg_repo AS r1
+    JOIN leaders ON leaders.owner = r1.owner
     WHERE LOWER(r1.language) = LOWER($1)
 )
 SELECT
@@ -144,7 +206,8 @@ SELECT
     ranked_repos.total_stars,
     ranked_repos.rownum,
     COALESCE(agg_user.name, '')::text AS display_name,
-    COALESCE(agg_user.type, '')::text AS type
+    COALESCE(agg_user.type, '')::text AS type,
+    ranked_repos.total
 FROM ranked_repos
 JOIN agg_user ON agg_user.login = ranked_repos.owner
 WHERE ranked_repos.rownum < 4
@@ -163,10 +226,17 @@ type LanguageLeadersRow struct {
 	Rownum          int64  `json:"rownum"`
 	DisplayName     string `json:"display_name"`
 	Type            string `json:"type"`
+	Total           int64  `json:"total"`
+}
+
+type LanguageLeadersParams struct {
+	Language string `json:"language"`
+	Limit    int32  `json:"limit"`
+	Offset   int32  `json:"offset"`
 }
 
-func (q *Queries) LanguageLeaders(ctx context.Context, lower string) ([]LanguageLeadersRow, error) {
-	rows, err := q.db.QueryContext(ctx, languageLeaders, lower)
+func (q *Queries) LanguageLeaders(ctx context.Context, arg LanguageLeadersParams) ([]LanguageLeadersRow, error) {
+	rows, err := q.db.QueryContext(ctx, languageLeaders, arg.Language, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -186,6 +256,7 @@ func (q *Queries) LanguageLeaders(ctx context.Context, lower string) ([]Language
 			&i.Rownum,
 			&i.DisplayName,
 			&i.Type,
+			&i.Total,
 		); err != nil {
 			return nil, err
 		}
@@ -242,6 +313,62 @@ func (q *Queries) PopularLanguages(ctx context.Context) ([]PopularLanguagesRow,
 	return items, nil
 }
 
+const reposByOwner = `-- name: ReposByOwner :many
+SELECT owner, name, description, language, homepage, forks_count,
+       open_issues_count, stargazers_count, watchers_count, fork, default_branch
+FROM agg_repo
+WHERE owner = $1
+`
+
+type ReposByOwnerRow struct {
+	Owner           string         `json:"owner"`
+	Name            string         `json:"name"`
+	Description     sql.NullString `json:"description"`
+	Language        sql.NullString `json:"language"`
+	Homepage        sql.NullString `json:"homepage"`
+	ForksCount      sql.NullInt32  `json:"forks_count"`
+	OpenIssuesCount sql.NullInt32  `json:"open_issues_count"`
+	StargazersCount sql.NullInt32  `json:"stargazers_count"`
+	WatchersCount   sql.NullInt32  `json:"watchers_count"`
+	Fork            sql.NullBool   `json:"fork"`
+	DefaultBranch   sql.NullString `json:"default_branch"`
+}
+
+func (q *Queries) ReposByOwner(ctx context.Context, owner string) ([]ReposByOwnerRow, error) {
+	rows, err := q.db.QueryContext(ctx, reposByOwner, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReposByOwnerRow
+	for rows.Next() {
+		var i ReposByOwnerRow
+		if err := rows.Scan(
+			&i.Owner,
+			&i.Name,
+			&i.Description,
+			&i.Language,
+			&i.Homepage,
+			&i.ForksCount,
+			&i.OpenIssuesCount,
+			&i.StargazersCount,
+			&i.WatchersCount,
+			&i.Fork,
+			&i.DefaultBranch,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const reposForUser = `-- name: ReposForUser :many
 SELECT
     owner,
@@ -353,14 +480,45 @@ SELECT
     created_at,
     pushed_at,
     updated_at,
-    refreshed_at
+    refreshed_at,
+    COUNT(*) OVER () AS total
 FROM agg_repo
-WHERE LOWER(name) LIKE LOWER($1)
-   OR LOWER(description) LIKE LOWER($1)
-ORDER BY stargazers_count DESC
-LIMIT 50
+WHERE (
+      $1::text = '' OR
+      search @@ websearch_to_tsquery('english', $1::text) OR
+      name % $1::text
+  )
+  AND ($2::text IS NULL OR LOWER(language) = LOWER($2::text))
+  AND ($3::text IS NULL OR LOWER(owner) = LOWER($3::text))
+  AND EXISTS (
+      SELECT 1 FROM agg_user
+      WHERE agg_user.login = agg_repo.owner
+        AND agg_user.hide IS FALSE
+        AND agg_user.outside_region IS FALSE
+        AND ($4::text IS NULL OR LOWER(agg_user.type) = LOWER($4::text))
+        AND ($5::text IS NULL OR LOWER(agg_user.company) = LOWER($5::text))
+  )
+ORDER BY
+    (
+        ts_rank(search, websearch_to_tsquery('english', $1::text)) +
+        similarity(name, $1::text)
+    ) * (1 + LN(1 + COALESCE(stargazers_count, 0))) DESC,
+    stargazers_count DESC NULLS LAST,
+    owner,
+    name
+LIMIT $6 OFFSET $7
 `
 
+type SearchReposParams struct {
+	Query    string         `json:"query"`
+	Language sql.NullString `json:"language"`
+	Owner    sql.NullString `json:"owner"`
+	DevType  sql.NullString `json:"dev_type"`
+	Company  sql.NullString `json:"company"`
+	Limit    int32          `json:"limit"`
+	Offset   int32          `json:"offset"`
+}
+
 type SearchReposRow struct {
 	Owner            string       `json:"owner"`
 	Name             string       `json:"name"`
@@ -381,10 +539,19 @@ type SearchReposRow struct {
 	PushedAt         sql.NullTime `json:"pushed_at"`
 	UpdatedAt        sql.NullTime `json:"updated_at"`
 	RefreshedAt      sql.NullTime `json:"refreshed_at"`
+	Total            int64        `json:"total"`
 }
 
-func (q *Queries) SearchRepos(ctx context.Context, lower string) ([]SearchReposRow, error) {
-	rows, err := q.db.QueryContext(ctx, searchRepos, lower)
+func (q *Queries) SearchRepos(ctx context.Context, arg SearchReposParams) ([]SearchReposRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchRepos,
+		arg.Query,
+		arg.Language,
+		arg.Owner,
+		arg.DevType,
+		arg.Company,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -412,6 +579,7 @@ func (q *Queries) SearchRepos(ctx context.Context, lower string) ([]SearchReposR
 			&i.PushedAt,
 			&i.UpdatedAt,
 			&i.RefreshedAt,
+			&i.Total,
 		); err != nil {
 			return nil, err
 		}
@@ -10,6 +10,76 @@ import (
 	"database/sql"
 )
 
+const countPopularDevs = `-- name: CountPopularDevs :one
+SELECT COUNT(*)
+FROM agg_user
+JOIN (
+        SELECT DISTINCT owner
+        FROM agg_repo
+) AS repo ON repo.owner = agg_user.login
+WHERE agg_user.type = $1
+    AND agg_user.hide IS FALSE
+    AND agg_user.outside_region IS FALSE
+    AND (
+        $2::text IS NULL OR
+        LOWER(agg_user.company) LIKE LOWER($2::text)
+    )
+`
+
+type CountPopularDevsParams struct {
+	DevType        sql.NullString `json:"dev_type"`
+	CompanyPattern sql.NullString `json:"company_pattern"`
+}
+
+func (q *Queries) CountPopularDevs(ctx context.Context, arg CountPopularDevsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPopularDevs, arg.DevType, arg.CompanyPattern)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countSearchUsers = `-- name: CountSearchUsers :one
+SELECT COUNT(*)
+FROM agg_user
+WHERE agg_user.hide IS FALSE
+  AND agg_user.outside_region IS FALSE
+  AND (
+      $1::text = '' OR
+      agg_user.search @@ websearch_to_tsquery('english', $1::text) OR
+      agg_user.login % $1::text OR
+      agg_user.name % $1::text
+  )
+  AND ($2::text IS NULL OR LOWER(agg_user.type) = LOWER($2::text))
+  AND ($3::text IS NULL OR LOWER(agg_user.company) = LOWER($3::text))
+  AND (
+      $4::text IS NULL OR
+      EXISTS (
+          SELECT 1 FROM agg_repo
+          WHERE agg_repo.owner = agg_user.login
+            AND LOWER(agg_repo.language) = LOWER($4::text)
+      )
+  )
+`
+
+type CountSearchUsersParams struct {
+	Query    string         `json:"query"`
+	DevType  sql.NullString `json:"dev_type"`
+	Company  sql.NullString `json:"company"`
+	Language sql.NullString `json:"language"`
+}
+
+func (q *Queries) CountSearchUsers(ctx context.Context, arg CountSearchUsersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSearchUsers,
+		arg.Query,
+		arg.DevType,
+		arg.Company,
+		arg.Language,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const deleteUser = `-- name: DeleteUser :exec
 DELETE FROM agg_user
 WHERE login = $1
@@ -41,6 +111,11 @@ SELECT
     agg_user.company,
     agg_user.hide,
     agg_user.is_admin,
+    agg_user.outside_region,
+    EXISTS (
+        SELECT 1 FROM agg_opt_out
+        WHERE LOWER(agg_opt_out.login) = LOWER(agg_user.login)
+    ) AS opted_out,
     COALESCE(repo.stars, 0)::int AS stars,
     COALESCE(repo.forks, 0)::int AS forks
 FROM agg_user
@@ -53,27 +128,29 @@ WHERE login = $1
 `
 
 type GetUserRow struct {
-	Login       string       `json:"login"`
-	Email       string       `json:"email"`
-	Name        string       `json:"name"`
-	Location    string       `json:"location"`
-	Hireable    bool         `json:"hireable"`
-	Blog        string       `json:"blog"`
-	Bio         string       `json:"bio"`
-	Followers   int32        `json:"followers"`
-	Following   int32        `json:"following"`
-	PublicRepos int32        `json:"public_repos"`
-	PublicGists int32        `json:"public_gists"`
-	AvatarUrl   string       `json:"avatar_url"`
-	Type        string       `json:"type"`
-	DiskUsage   int32        `json:"disk_usage"`
-	CreatedAt   sql.NullTime `json:"created_at"`
-	UpdatedAt   sql.NullTime `json:"updated_at"`
-	Company     string       `json:"company"`
-	Hide        bool         `json:"hide"`
-	IsAdmin     bool         `json:"is_admin"`
-	Stars       int32        `json:"stars"`
-	Forks       int32        `json:"forks"`
+	Login         string       `json:"login"`
+	Email         string       `json:"email"`
+	Name          string       `json:"name"`
+	Location      string       `json:"location"`
+	Hireable      bool         `json:"hireable"`
+	Blog          string       `json:"blog"`
+	Bio           string       `json:"bio"`
+	Followers     int32        `json:"followers"`
+	Following     int32        `json:"following"`
+	PublicRepos   int32        `json:"public_repos"`
+	PublicGists   int32        `json:"public_gists"`
+	AvatarUrl     string       `json:"avatar_url"`
+	Type          string       `json:"type"`
+	DiskUsage     int32        `json:"disk_usage"`
+	CreatedAt     sql.NullTime `json:"created_at"`
+	UpdatedAt     sql.NullTime `json:"updated_at"`
+	Company       string       `json:"company"`
+	Hide          bool         `json:"hide"`
+	IsAdmin       bool         `json:"is_admin"`
+	OutsideRegion bool         `json:"outside_region"`
+	OptedOut      bool         `json:"opted_out"`
+	Stars         int32        `json:"stars"`
+	Forks         int32        `json:"forks"`
 }
 
 func (q *Queries) GetUser(ctx context.Context, login string) (GetUserRow, error) {
@@ -99,6 +176,8 @@ func (q *Queries) GetUser(ctx context.Context, login string) (GetUserRow, error)
 		&i.Company,
 		&i.Hide,
 		&i.IsAdmin,
+		&i.OutsideRegion,
+		&i.OptedOut,
 		&i.Stars,
 		&i.Forks,
 	)
@@ -205,7 +284,8 @@ SELECT
         COALESCE(agg_user.public_repos, 0)::int AS public_repos,
         repo.stars::int AS stars,
         repo.forks::int AS forks,
-        COALESCE(agg_user.type, '')::text AS type
+        COALESCE(agg_user.type, '')::text AS type,
+        COUNT(*) OVER () AS total
 FROM agg_user
 JOIN (
         SELECT owner, SUM(stargazers_count) AS stars, SUM(forks_count) AS forks
@@ -214,6 +294,7 @@ JOIN (
 ) AS repo ON repo.owner = agg_user.login
 WHERE agg_user.type = $1
     AND agg_user.hide IS FALSE
+    AND agg_user.outside_region IS FALSE
     AND (
         $2::text IS NULL OR
         LOWER(agg_user.company) LIKE LOWER($2::text)
@@ -223,14 +304,17 @@ ORDER BY
     CASE WHEN $3::text = 'forks' THEN repo.forks END DESC,
     CASE WHEN $3::text = 'followers' THEN agg_user.followers END DESC,
     CASE WHEN $3::text = 'public_repos' THEN agg_user.public_repos END DESC,
-    repo.stars DESC
-LIMIT 100
+    repo.stars DESC,
+    agg_user.login
+LIMIT $4 OFFSET $5
 `
 
 type PopularDevsParams struct {
 	DevType        sql.NullString `json:"dev_type"`
 	CompanyPattern sql.NullString `json:"company_pattern"`
 	SortBy         string         `json:"sort_by"`
+	Limit          int32          `json:"limit"`
+	Offset         int32          `json:"offset"`
 }
 
 type PopularDevsRow struct {
@@ -243,10 +327,17 @@ type PopularDevsRow struct {
 	Stars       int32  `json:"stars"`
 	Forks       int32  `json:"forks"`
 	Type        string `json:"type"`
+	Total       int64  `json:"total"`
 }
 
 func (q *Queries) PopularDevs(ctx context.Context, arg PopularDevsParams) ([]PopularDevsRow, error) {
-	rows, err := q.db.QueryContext(ctx, popularDevs, arg.DevType, arg.CompanyPattern, arg.SortBy)
+	rows, err := q.db.QueryContext(ctx, popularDevs,
+		arg.DevType,
+		arg.CompanyPattern,
+		arg.SortBy,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -264,6 +355,7 @@ func (q *Queries) PopularDevs(ctx context.Context, arg PopularDevsParams) ([]Pop
 			&i.Stars,
 			&i.Forks,
 			&i.Type,
+			&i.Total,
 		); err != nil {
 			return nil, err
 		}
@@ -278,6 +370,35 @@ func (q *Queries) PopularDevs(ctx context.Context, arg PopularDevsParams) ([]Pop
 	return items, nil
 }
 
+const regionUsers = `-- name: RegionUsers :many
+SELECT login
+FROM agg_user
+WHERE type = 'User' AND outside_region IS FALSE
+`
+
+func (q *Queries) RegionUsers(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, regionUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, err
+		}
+		items = append(items, login)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const searchUsers = `-- name: SearchUsers :many
 SELECT
     agg_user.login,
@@ -290,18 +411,51 @@ SELECT
     agg_user.hide,
     agg_user.is_admin,
     COALESCE(repo.stars, 0)::int AS stars,
-    COALESCE(repo.forks, 0)::int AS forks
+    COALESCE(repo.forks, 0)::int AS forks,
+    COUNT(*) OVER () AS total
 FROM agg_user
 LEFT JOIN (
     SELECT owner, SUM(stargazers_count) AS stars, SUM(forks_count) AS forks
     FROM agg_repo
     GROUP BY owner
 ) AS repo ON repo.owner = agg_user.login
-WHERE login LIKE $1
-ORDER BY stars DESC
-LIMIT 50
+WHERE agg_user.hide IS FALSE
+  AND agg_user.outside_region IS FALSE
+  AND (
+      $1::text = '' OR
+      agg_user.search @@ websearch_to_tsquery('english', $1::text) OR
+      agg_user.login % $1::text OR
+      agg_user.name % $1::text
+  )
+  AND ($2::text IS NULL OR LOWER(agg_user.type) = LOWER($2::text))
+  AND ($3::text IS NULL OR LOWER(agg_user.company) = LOWER($3::text))
+  AND (
+      $4::text IS NULL OR
+      EXISTS (
+          SELECT 1 FROM agg_repo
+          WHERE agg_repo.owner = agg_user.login
+            AND LOWER(agg_repo.language) = LOWER($4::text)
+      )
+  )
+ORDER BY
+    (
+        ts_rank(agg_user.search, websearch_to_tsquery('english', $1::text)) +
+        GREATEST(similarity(agg_user.login, $1::text), similarity(COALESCE(agg_user.name, ''), $1::text))
+    ) * (1 + LN(1 + COALESCE(repo.stars, 0))) DESC,
+    stars DESC,
+    agg_user.login
+LIMIT $5 OFFSET $6
 `
 
+type SearchUsersParams struct {
+	Query    string         `json:"query"`
+	DevType  sql.NullString `json:"dev_type"`
+	Company  sql.NullString `json:"company"`
+	Language sql.NullString `json:"language"`
+	Limit    int32          `json:"limit"`
+	Offset   int32          `json:"offset"`
+}
+
 type SearchUsersRow struct {
 	Login       string `json:"login"`
 	Name        string `json:"name"`
@@ -314,10 +468,18 @@ type SearchUsersRow struct {
 	IsAdmin     bool   `json:"is_admin"`
 	Stars       int32  `json:"stars"`
 	Forks       int32  `json:"forks"`
+	Total       int64  `json:"total"`
 }
 
-func (q *Queries) SearchUsers(ctx context.Context, login string) ([]SearchUsersRow, error) {
-	rows, err := q.db.QueryContext(ctx, searchUsers, login)
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.Query,
+		arg.DevType,
+		arg.Company,
+		arg.Language,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -337,6 +499,7 @@ func (q *Queries) SearchUsers(ctx context.Context, login string) ([]SearchUsersR
 			&i.IsAdmin,
 			&i.Stars,
 			&i.Forks,
+			&i.Total,
 		); err != nil {
 			return nil, err
 		}
@@ -351,6 +514,52 @@ func (q *Queries) SearchUsers(ctx context.Context, login string) ([]SearchUsersR
 	return items, nil
 }
 
+const setUserOutsideRegion = `-- name: SetUserOutsideRegion :exec
+UPDATE agg_user
+SET outside_region = $2
+WHERE login = $1
+`
+
+type SetUserOutsideRegionParams struct {
+	Login         string `json:"login"`
+	OutsideRegion bool   `json:"outside_region"`
+}
+
+func (q *Queries) SetUserOutsideRegion(ctx context.Context, arg SetUserOutsideRegionParams) error {
+	_, err := q.db.ExecContext(ctx, setUserOutsideRegion, arg.Login, arg.OutsideRegion)
+	return err
+}
+
+const staleUsers = `-- name: StaleUsers :many
+SELECT login
+FROM agg_user
+WHERE refreshed_at IS NULL OR refreshed_at < $1
+ORDER BY refreshed_at NULLS FIRST
+`
+
+func (q *Queries) StaleUsers(ctx context.Context, refreshedAt sql.NullTime) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, staleUsers, refreshedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, err
+		}
+		items = append(items, login)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateUser = `-- name: UpdateUser :execrows
 UPDATE agg_user
 SET
@@ -422,3 +631,65 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (int64,
 	}
 	return result.RowsAffected()
 }
+
+const userByLogin = `-- name: UserByLogin :one
+SELECT login, email, location, hireable, blog, bio, followers, following,
+       public_repos, public_gists, avatar_url, type, name, company, outside_region
+FROM agg_user
+WHERE login = $1
+`
+
+type UserByLoginRow struct {
+	Login         string         `json:"login"`
+	Email         sql.NullString `json:"email"`
+	Location      sql.NullString `json:"location"`
+	Hireable      sql.NullBool   `json:"hireable"`
+	Blog          sql.NullString `json:"blog"`
+	Bio           sql.NullString `json:"bio"`
+	Followers     sql.NullInt32  `json:"followers"`
+	Following     sql.NullInt32  `json:"following"`
+	PublicRepos   sql.NullInt32  `json:"public_repos"`
+	PublicGists   sql.NullInt32  `json:"public_gists"`
+	AvatarUrl     sql.NullString `json:"avatar_url"`
+	Type          sql.NullString `json:"type"`
+	Name          sql.NullString `json:"name"`
+	Company       string         `json:"company"`
+	OutsideRegion bool           `json:"outside_region"`
+}
+
+func (q *Queries) UserByLogin(ctx context.Context, login string) (UserByLoginRow, error) {
+	row := q.db.QueryRowContext(ctx, userByLogin, login)
+	var i UserByLoginRow
+	err := row.Scan(
+		&i.Login,
+		&i.Email,
+		&i.Location,
+		&i.Hireable,
+		&i.Blog,
+		&i.Bio,
+		&i.Followers,
+		&i.Following,
+		&i.PublicRepos,
+		&i.PublicGists,
+		&i.AvatarUrl,
+		&i.Type,
+		&i.Name,
+		&i.Company,
+		&i.OutsideRegion,
+	)
+	return i, err
+}
+
+const userHidden = `-- name: UserHidden :one
+SELECT hide
+FROM agg_user
+WHERE login = $1
+FOR UPDATE
+`
+
+func (q *Queries) UserHidden(ctx context.Context, login string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, userHidden, login)
+	var hide bool
+	err := row.Scan(&hide)
+	return hide, err
+}
@@ -7,6 +7,7 @@ require (
 	github.com/google/go-github/v52 v52.0.0
 	github.com/jackc/pgx/v5 v5.7.6
 	github.com/jakecoffman/crud v1.6.0
+	github.com/robfig/cron/v3 v3.0.1
 	golang.org/x/oauth2 v0.27.0
 )
 
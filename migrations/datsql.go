@@ -54,4 +54,250 @@ const (
 	migrationOrganizations = `ALTER TABLE agg_user
 		ADD COLUMN type VARCHAR(255),
 		ADD COLUMN name VARCHAR(255)`
+
+	createHTTPCache = `CREATE TABLE IF NOT EXISTS agg_http_cache (
+			url TEXT PRIMARY KEY,
+			etag TEXT NOT NULL DEFAULT '',
+			last_modified TEXT NOT NULL DEFAULT '',
+			link TEXT NOT NULL DEFAULT '',
+			body BYTEA NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+			);`
+
+	createRun = `CREATE TABLE IF NOT EXISTS agg_run (
+			id BIGSERIAL PRIMARY KEY,
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ,
+			status VARCHAR(16) NOT NULL,
+			users_discovered INTEGER NOT NULL DEFAULT 0,
+			users_updated INTEGER NOT NULL DEFAULT 0,
+			repos_inserted INTEGER NOT NULL DEFAULT 0,
+			repos_updated INTEGER NOT NULL DEFAULT 0,
+			repos_deleted INTEGER NOT NULL DEFAULT 0,
+			api_calls INTEGER NOT NULL DEFAULT 0
+			);`
+
+	createRunError = `CREATE TABLE IF NOT EXISTS agg_run_error (
+			id BIGSERIAL PRIMARY KEY,
+			run_id BIGINT NOT NULL REFERENCES agg_run (id) ON DELETE CASCADE,
+			login VARCHAR(255) NOT NULL DEFAULT '',
+			message TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL
+			);`
+
+	migrationRunHistory = `INSERT INTO agg_run (started_at, finished_at, status)
+		SELECT created_at, created_at, 'succeeded'
+		FROM agg_meta
+		WHERE created_at IS NOT NULL`
+
+	createRunUser = `CREATE TABLE IF NOT EXISTS agg_run_user (
+			run_id BIGINT NOT NULL REFERENCES agg_run (id) ON DELETE CASCADE,
+			login VARCHAR(255) NOT NULL,
+			done BOOLEAN NOT NULL DEFAULT FALSE,
+			PRIMARY KEY (run_id, login)
+			);`
+
+	migrationOutsideRegion = `ALTER TABLE agg_user
+		ADD COLUMN IF NOT EXISTS outside_region BOOLEAN NOT NULL DEFAULT FALSE`
+
+	createLocationOverride = `CREATE TABLE IF NOT EXISTS agg_location_override (
+			login VARCHAR(255) PRIMARY KEY,
+			include BOOLEAN NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			created_by VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL
+			);`
+
+	createOrgRegistry = `CREATE TABLE IF NOT EXISTS agg_org_registry (
+			login VARCHAR(255) PRIMARY KEY,
+			reason TEXT NOT NULL DEFAULT '',
+			added_by VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);`
+
+	seedOrgRegistry = `INSERT INTO agg_org_registry (login, reason)
+		VALUES ($1, 'seeded from orgs.txt')
+		ON CONFLICT DO NOTHING`
+
+	createOptIn = `CREATE TABLE IF NOT EXISTS agg_opt_in (
+			login VARCHAR(255) PRIMARY KEY,
+			requested_at TIMESTAMPTZ NOT NULL
+			);`
+
+	migrationRunMode = `ALTER TABLE agg_run
+		ADD COLUMN IF NOT EXISTS mode VARCHAR(16) NOT NULL DEFAULT 'full'`
+
+	createRepoSnapshot = `CREATE TABLE IF NOT EXISTS agg_repo_snapshot (
+			run_id BIGINT NOT NULL REFERENCES agg_run (id) ON DELETE CASCADE,
+			owner VARCHAR(255) NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			taken_at TIMESTAMPTZ NOT NULL,
+			stargazers_count INTEGER NOT NULL DEFAULT 0,
+			forks_count INTEGER NOT NULL DEFAULT 0,
+			watchers_count INTEGER NOT NULL DEFAULT 0,
+			open_issues_count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (run_id, owner, name)
+			);`
+
+	createRepoSnapshotIndex = `CREATE INDEX IF NOT EXISTS agg_repo_snapshot_repo
+		ON agg_repo_snapshot (owner, name, taken_at)`
+
+	createUserSnapshot = `CREATE TABLE IF NOT EXISTS agg_user_snapshot (
+			run_id BIGINT NOT NULL REFERENCES agg_run (id) ON DELETE CASCADE,
+			login VARCHAR(255) NOT NULL,
+			taken_at TIMESTAMPTZ NOT NULL,
+			followers INTEGER NOT NULL DEFAULT 0,
+			public_repos INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (run_id, login)
+			);`
+
+	createUserSnapshotIndex = `CREATE INDEX IF NOT EXISTS agg_user_snapshot_login
+		ON agg_user_snapshot (login, taken_at)`
+
+	createLease = `CREATE TABLE IF NOT EXISTS agg_lease (
+			name VARCHAR(255) PRIMARY KEY,
+			holder VARCHAR(255) NOT NULL,
+			acquired_at TIMESTAMPTZ NOT NULL,
+			heartbeat_at TIMESTAMPTZ NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+			);`
+
+	createTrigramExtension = `CREATE EXTENSION IF NOT EXISTS pg_trgm`
+
+	migrationUserSearch = `ALTER TABLE agg_user
+		ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', COALESCE(login, '')), 'A') ||
+			setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+			setweight(to_tsvector('english', COALESCE(company, '')), 'B') ||
+			setweight(to_tsvector('english', COALESCE(bio, '')), 'C')
+		) STORED`
+
+	createUserSearchIndex = `CREATE INDEX IF NOT EXISTS agg_user_search
+		ON agg_user USING GIN (search)`
+
+	createUserLoginTrigramIndex = `CREATE INDEX IF NOT EXISTS agg_user_login_trgm
+		ON agg_user USING GIN (login gin_trgm_ops)`
+
+	createUserNameTrigramIndex = `CREATE INDEX IF NOT EXISTS agg_user_name_trgm
+		ON agg_user USING GIN (name gin_trgm_ops)`
+
+	migrationRepoSearch = `ALTER TABLE agg_repo
+		ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+			setweight(to_tsvector('english', COALESCE(description, '')), 'B') ||
+			setweight(to_tsvector('english', COALESCE(language, '')), 'C')
+		) STORED`
+
+	createRepoSearchIndex = `CREATE INDEX IF NOT EXISTS agg_repo_search
+		ON agg_repo USING GIN (search)`
+
+	createRepoNameTrigramIndex = `CREATE INDEX IF NOT EXISTS agg_repo_name_trgm
+		ON agg_repo USING GIN (name gin_trgm_ops)`
+
+	createOptOut = `CREATE TABLE IF NOT EXISTS agg_opt_out (
+			login VARCHAR(255) PRIMARY KEY,
+			requested_by VARCHAR(255) NOT NULL,
+			requested_at TIMESTAMPTZ NOT NULL
+			);`
+
+	createOptOutIndex = `CREATE UNIQUE INDEX IF NOT EXISTS agg_opt_out_lower_login
+		ON agg_opt_out (LOWER(login))`
+
+	// token_hash is a SHA-256 of the cookie, so the table alone can't be used to
+	// log in as anyone
+	createSession = `CREATE TABLE IF NOT EXISTS agg_session (
+			token_hash BYTEA PRIMARY KEY,
+			login VARCHAR(255) NOT NULL,
+			user_data JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			last_seen_at TIMESTAMPTZ NOT NULL
+			);`
+
+	createSessionLoginIndex = `CREATE INDEX IF NOT EXISTS agg_session_login
+		ON agg_session (login)`
+
+	// sessions only keep who logged in, the rest of the user is loaded fresh
+	migrationSessionIdentity = `ALTER TABLE agg_session RENAME COLUMN user_data TO identity`
+
+	createRole = `CREATE TABLE IF NOT EXISTS agg_role (
+			name VARCHAR(64) PRIMARY KEY,
+			description TEXT NOT NULL DEFAULT ''
+			);`
+
+	createRolePermission = `CREATE TABLE IF NOT EXISTS agg_role_permission (
+			role VARCHAR(64) NOT NULL REFERENCES agg_role (name) ON DELETE CASCADE,
+			permission VARCHAR(64) NOT NULL,
+			PRIMARY KEY (role, permission)
+			);`
+
+	createUserRole = `CREATE TABLE IF NOT EXISTS agg_user_role (
+			login VARCHAR(255) NOT NULL,
+			role VARCHAR(64) NOT NULL REFERENCES agg_role (name) ON DELETE CASCADE,
+			granted_by VARCHAR(255) NOT NULL,
+			granted_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (login, role)
+			);`
+
+	createUserRoleIndex = `CREATE UNIQUE INDEX IF NOT EXISTS agg_user_role_lower_login
+		ON agg_user_role (LOWER(login), role)`
+
+	// is_admin users keep every permission without being granted admin
+	seedRoles = `INSERT INTO agg_role (name, description) VALUES
+		('admin', 'Everything, including granting roles'),
+		('moderator', 'Hide, delete and place devs'),
+		('org-manager', 'Maintain the organization registry')
+		ON CONFLICT DO NOTHING`
+
+	seedRolePermissions = `INSERT INTO agg_role_permission (role, permission) VALUES
+		('admin', 'users.manage'),
+		('admin', 'users.delete'),
+		('admin', 'orgs.manage'),
+		('admin', 'overrides.manage'),
+		('admin', 'runs.manage'),
+		('admin', 'roles.manage'),
+		('moderator', 'users.manage'),
+		('moderator', 'users.delete'),
+		('moderator', 'overrides.manage'),
+		('org-manager', 'orgs.manage')
+		ON CONFLICT DO NOTHING`
+
+	// before and after are JSON, null when there was nothing before or after,
+	// such as for a deleted user
+	createAudit = `CREATE TABLE IF NOT EXISTS agg_audit (
+			id BIGSERIAL PRIMARY KEY,
+			actor VARCHAR(255) NOT NULL,
+			action VARCHAR(64) NOT NULL,
+			target VARCHAR(255) NOT NULL,
+			before JSONB NOT NULL DEFAULT 'null',
+			after JSONB NOT NULL DEFAULT 'null',
+			created_at TIMESTAMPTZ NOT NULL
+			);`
+
+	createAuditTargetIndex = `CREATE INDEX IF NOT EXISTS agg_audit_target
+		ON agg_audit (LOWER(target), id)`
+
+	createAuditActorIndex = `CREATE INDEX IF NOT EXISTS agg_audit_actor
+		ON agg_audit (LOWER(actor), id)`
+
+	createAuditAppendOnlyFunction = `CREATE OR REPLACE FUNCTION agg_audit_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'agg_audit is append-only';
+		END;
+		$$ LANGUAGE plpgsql`
+
+	createAuditAppendOnlyTrigger = `CREATE TRIGGER agg_audit_append_only
+		BEFORE UPDATE OR DELETE OR TRUNCATE ON agg_audit
+		FOR EACH STATEMENT EXECUTE FUNCTION agg_audit_append_only()`
+
+	seedAuditPermission = `INSERT INTO agg_role_permission (role, permission)
+		VALUES ('admin', 'audit.read')
+		ON CONFLICT DO NOTHING`
+
+	// the region's org list files imported into the registry, by the hash of
+	// their contents, so each version of a file is imported once
+	createOrgImport = `CREATE TABLE IF NOT EXISTS agg_org_import (
+			hash BYTEA PRIMARY KEY,
+			path TEXT NOT NULL,
+			imported_at TIMESTAMPTZ NOT NULL
+			);`
 )
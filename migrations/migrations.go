@@ -2,9 +2,16 @@ package migrations
 
 import (
 	"database/sql"
+	_ "embed"
 	"log"
+	"strings"
 )
 
+// orgList seeds the org registry, it's kept sorted by scripts/sort-orgs.sh.
+//
+//go:embed orgs.txt
+var orgList string
+
 type migration func(*sql.DB) error
 
 var migrations []migration
@@ -14,6 +21,22 @@ func init() {
 		genesis,
 		organizations,
 		userEnhancements,
+		httpCache,
+		runHistory,
+		runCheckpoints,
+		locationFiltering,
+		orgRegistry,
+		optIns,
+		runLease,
+		runModes,
+		snapshots,
+		fullTextSearch,
+		optOuts,
+		persistentSessions,
+		sessionIdentity,
+		roles,
+		auditLog,
+		orgImports,
 	}
 }
 
@@ -49,7 +72,65 @@ func genesis(db *sql.DB) error {
 }
 
 func organizations(db *sql.DB) error {
-	const name = "organizations"
+	return applyOnce(db, "organizations", migrationOrganizations)
+}
+
+func userEnhancements(db *sql.DB) error {
+	_, err := db.Exec("alter table agg_user add column if not exists hide boolean default false")
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	_, err = db.Exec("alter table agg_user add column if not exists is_admin boolean default false")
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE agg_user ADD COLUMN IF NOT EXISTS refreshed_at TIMESTAMPTZ`)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE agg_repo ADD COLUMN IF NOT EXISTS refreshed_at TIMESTAMPTZ`)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE agg_user ADD COLUMN IF NOT EXISTS company text not null`)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE agg_user ADD COLUMN IF NOT EXISTS company text not null`)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func httpCache(db *sql.DB) error {
+	return applyOnce(db, "httpCache", createHTTPCache)
+}
+
+// runHistory replaces the bare agg_meta timestamps with agg_run, carrying the
+// existing timestamps over as succeeded runs.
+func runHistory(db *sql.DB) error {
+	return applyOnce(db, "runHistory", createRun, createRunError, migrationRunHistory)
+}
+
+func runCheckpoints(db *sql.DB) error {
+	return applyOnce(db, "runCheckpoints", createRunUser)
+}
+
+func locationFiltering(db *sql.DB) error {
+	return applyOnce(db, "locationFiltering", migrationOutsideRegion, createLocationOverride)
+}
+
+// orgRegistry moves the organizations that used to be embedded in the
+// aggregator into a table so they can be managed without a deploy.
+func orgRegistry(db *sql.DB) error {
+	const name = "orgRegistry"
 	apply, err := shouldApply(db, name)
 	if err != nil {
 		log.Println(err)
@@ -64,10 +145,19 @@ func organizations(db *sql.DB) error {
 		return err
 	}
 	defer tx.Rollback()
-	if _, err := tx.Exec(migrationOrganizations); err != nil {
+	if _, err := tx.Exec(createOrgRegistry); err != nil {
 		log.Println(err)
 		return err
 	}
+	for _, org := range strings.Split(orgList, "\n") {
+		if org = strings.TrimSpace(org); org == "" {
+			continue
+		}
+		if _, err := tx.Exec(seedOrgRegistry, org); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
 	if _, err := tx.Exec(insertMigration, name); err != nil {
 		log.Println(err)
 		return err
@@ -76,38 +166,104 @@ func organizations(db *sql.DB) error {
 		log.Println(err)
 		return err
 	}
-
 	return nil
 }
 
-func userEnhancements(db *sql.DB) error {
-	_, err := db.Exec("alter table agg_user add column if not exists hide boolean default false")
+func optIns(db *sql.DB) error {
+	return applyOnce(db, "optIns", createOptIn)
+}
+
+func runLease(db *sql.DB) error {
+	return applyOnce(db, "runLease", createLease)
+}
+
+func runModes(db *sql.DB) error {
+	return applyOnce(db, "runModes", migrationRunMode)
+}
+
+func snapshots(db *sql.DB) error {
+	return applyOnce(db, "snapshots", createRepoSnapshot, createRepoSnapshotIndex, createUserSnapshot, createUserSnapshotIndex)
+}
+
+func fullTextSearch(db *sql.DB) error {
+	return applyOnce(db, "fullTextSearch",
+		createTrigramExtension,
+		migrationUserSearch,
+		createUserSearchIndex,
+		createUserLoginTrigramIndex,
+		createUserNameTrigramIndex,
+		migrationRepoSearch,
+		createRepoSearchIndex,
+		createRepoNameTrigramIndex,
+	)
+}
+
+func optOuts(db *sql.DB) error {
+	return applyOnce(db, "optOuts", createOptOut, createOptOutIndex)
+}
+
+func persistentSessions(db *sql.DB) error {
+	return applyOnce(db, "persistentSessions", createSession, createSessionLoginIndex)
+}
+
+func sessionIdentity(db *sql.DB) error {
+	return applyOnce(db, "sessionIdentity", migrationSessionIdentity)
+}
+
+func roles(db *sql.DB) error {
+	return applyOnce(db, "roles",
+		createRole,
+		createRolePermission,
+		createUserRole,
+		createUserRoleIndex,
+		seedRoles,
+		seedRolePermissions,
+	)
+}
+
+func auditLog(db *sql.DB) error {
+	return applyOnce(db, "auditLog",
+		createAudit,
+		createAuditTargetIndex,
+		createAuditActorIndex,
+		createAuditAppendOnlyFunction,
+		createAuditAppendOnlyTrigger,
+		seedAuditPermission,
+	)
+}
+
+func orgImports(db *sql.DB) error {
+	return applyOnce(db, "orgImports", createOrgImport)
+}
+
+// applyOnce runs the statements in a transaction and records the migration by
+// name, skipping it if it has already been recorded.
+func applyOnce(db *sql.DB, name string, statements ...string) error {
+	apply, err := shouldApply(db, name)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	_, err = db.Exec("alter table agg_user add column if not exists is_admin boolean default false")
-	if err != nil {
-		log.Println(err)
-		return err
+	if !apply {
+		return nil
 	}
-	_, err = db.Exec(`ALTER TABLE agg_user ADD COLUMN IF NOT EXISTS refreshed_at TIMESTAMPTZ`)
+	tx, err := db.Begin()
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	_, err = db.Exec(`ALTER TABLE agg_repo ADD COLUMN IF NOT EXISTS refreshed_at TIMESTAMPTZ`)
-	if err != nil {
-		log.Println(err)
-		return err
+	defer tx.Rollback()
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			log.Println(err)
+			return err
+		}
 	}
-	_, err = db.Exec(`ALTER TABLE agg_user ADD COLUMN IF NOT EXISTS company text not null`)
-	if err != nil {
+	if _, err := tx.Exec(insertMigration, name); err != nil {
 		log.Println(err)
 		return err
 	}
-	_, err = db.Exec(`ALTER TABLE agg_user ADD COLUMN IF NOT EXISTS company text not null`)
-	if err != nil {
+	if err = tx.Commit(); err != nil {
 		log.Println(err)
 		return err
 	}
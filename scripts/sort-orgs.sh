@@ -1,6 +1,6 @@
 #!/bin/bash
 
-# This script ensures the aggregator/orgs.txt list is sorted
+# This script ensures the migrations/orgs.txt list is sorted
 
 # Usage: ./scripts/sort-orgs.sh
 
@@ -10,9 +10,9 @@ if [ ! -f "scripts/sort-orgs.sh" ]; then
   exit 1
 fi
 
-if [ ! -f "aggregator/orgs.txt" ]; then
-  echo "The file aggregator/orgs.txt does not exist."
+if [ ! -f "migrations/orgs.txt" ]; then
+  echo "The file migrations/orgs.txt does not exist."
   exit 1
 fi
 
-sort -o aggregator/orgs.txt aggregator/orgs.txt
+sort -o migrations/orgs.txt migrations/orgs.txt
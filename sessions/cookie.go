@@ -0,0 +1,40 @@
+package sessions
+
+import (
+	"net/http"
+	"time"
+)
+
+// CookieConfig is how the session cookie is set in an environment.
+type CookieConfig struct {
+	Path string
+	// Secure keeps the cookie off plain HTTP, which development doesn't have.
+	Secure bool
+}
+
+// ProdCookieConfig is the session cookie served over HTTPS.
+var ProdCookieConfig = CookieConfig{Path: "/", Secure: true}
+
+// DebugOnlyCookieConfig allows the session cookie over HTTP for development.
+var DebugOnlyCookieConfig = CookieConfig{Path: "/"}
+
+// Cookie is the session cookie holding value. It's never readable by scripts,
+// and Lax keeps it off requests other sites make, other than links to here.
+func (c CookieConfig) Cookie(value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     Cookie,
+		Value:    value,
+		Path:     c.Path,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Expired is the cookie that removes the session cookie.
+func (c CookieConfig) Expired() *http.Cookie {
+	cookie := c.Cookie("", time.Time{})
+	cookie.MaxAge = -1
+	return cookie
+}
@@ -8,11 +8,12 @@ import (
 	"time"
 
 	"github.com/dghubble/gologin/v2/github"
-	"github.com/jakecoffman/stldevs/db"
-	"github.com/jakecoffman/stldevs/db/sqlc"
 )
 
-type Issuer struct{}
+// Issuer starts a session for the user GitHub just logged in.
+type Issuer struct {
+	Cookie CookieConfig
+}
 
 func (s *Issuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	githubUser, err := github.UserFromContext(r.Context())
@@ -25,30 +26,19 @@ func (s *Issuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	log.Println("Login success", *githubUser.Login)
 
-	user, err := db.GetUser(*githubUser.Login)
-	if err != nil || user.Login == "" {
-		// user not found or something?
-		user = sqlc.GetUserRow{
-			Login: *githubUser.Login,
-		}
-		if githubUser.AvatarURL != nil {
-			user.AvatarUrl = *githubUser.AvatarURL
-		}
-		if githubUser.Name != nil {
-			user.Name = *githubUser.Name
-		}
-		if githubUser.Email != nil {
-			user.Email = *githubUser.Email
-		}
+	identity := Identity{
+		Login:     githubUser.GetLogin(),
+		Name:      githubUser.GetName(),
+		AvatarUrl: githubUser.GetAvatarURL(),
+		Email:     githubUser.GetEmail(),
 	}
-
-	expire := time.Now().AddDate(0, 0, 1)
-	cookie := http.Cookie{
-		Name:    Cookie,
-		Value:   Store.Add(&user),
-		Expires: expire,
+	token, err := Store.Add(r.Context(), identity)
+	if err != nil {
+		w.WriteHeader(500)
+		_, _ = w.Write([]byte("\"failed to start session\""))
+		return
 	}
-	http.SetCookie(w, &cookie)
+	http.SetCookie(w, s.Cookie.Cookie(token, time.Now().Add(AbsoluteTimeout)))
 	http.Redirect(w, r, "/you", http.StatusFound)
 }
 
@@ -0,0 +1,93 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// touchInterval is how stale a session's last use can get before it's
+// written, so every request isn't also an update.
+const touchInterval = time.Minute
+
+// PostgresStore keeps sessions in the agg_session table, so they survive
+// restarts and are shared by every instance.
+type PostgresStore struct {
+	queries *sqlc.Queries
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{queries: sqlc.New(db)}
+}
+
+// hashToken is how a cookie is stored, the cookie itself never is.
+func hashToken(cookie string) []byte {
+	sum := sha256.Sum256([]byte(cookie))
+	return sum[:]
+}
+
+func (s *PostgresStore) Get(ctx context.Context, cookie string) (Entry, bool) {
+	t := now()
+	row, err := s.queries.GetSession(ctx, sqlc.GetSessionParams{
+		TokenHash:    hashToken(cookie),
+		CreatedAfter: t.Add(-AbsoluteTimeout),
+		SeenAfter:    t.Add(-IdleTimeout),
+	})
+	if err == sql.ErrNoRows {
+		return Entry{}, false
+	}
+	if err != nil {
+		log.Println("GetSession query failed:", err)
+		return Entry{}, false
+	}
+	var identity Identity
+	if err = json.Unmarshal(row.Identity, &identity); err != nil {
+		log.Println("Failed reading session of", row.Login, err)
+		return Entry{}, false
+	}
+	if t.Sub(row.LastSeenAt) >= touchInterval {
+		err = s.queries.TouchSession(ctx, sqlc.TouchSessionParams{TokenHash: row.TokenHash, LastSeenAt: t})
+		if err != nil {
+			log.Println("TouchSession update failed:", err)
+		}
+	}
+	return Entry{Identity: identity, Created: row.CreatedAt, LastSeen: t}, true
+}
+
+func (s *PostgresStore) Add(ctx context.Context, identity Identity) (string, error) {
+	data, err := json.Marshal(identity)
+	if err != nil {
+		return "", err
+	}
+	cookie := GenerateSessionCookie()
+	err = s.queries.InsertSession(ctx, sqlc.InsertSessionParams{
+		TokenHash: hashToken(cookie),
+		Login:     identity.Login,
+		Identity:  data,
+		CreatedAt: now(),
+	})
+	if err != nil {
+		log.Println("InsertSession failed:", err)
+		return "", err
+	}
+	return cookie, nil
+}
+
+func (s *PostgresStore) Evict(ctx context.Context, cookie string) {
+	if err := s.queries.DeleteSession(ctx, hashToken(cookie)); err != nil {
+		log.Println("DeleteSession failed:", err)
+	}
+}
+
+func (s *PostgresStore) Sweep(ctx context.Context) (int64, error) {
+	t := now()
+	return s.queries.DeleteExpiredSessions(ctx, sqlc.DeleteExpiredSessionsParams{
+		CreatedBefore: t.Add(-AbsoluteTimeout),
+		SeenBefore:    t.Add(-IdleTimeout),
+	})
+}
@@ -1,7 +1,10 @@
 package sessions
 
 import (
+	"context"
+	"log"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
@@ -11,13 +14,34 @@ import (
 const (
 	Cookie     = "stldevs-session"
 	KeySession = "session"
+
+	// AbsoluteTimeout is how long a session lasts after logging in, however
+	// much it's used.
+	AbsoluteTimeout = 7 * 24 * time.Hour
+	// IdleTimeout ends sessions that haven't been used for a while.
+	IdleTimeout = 24 * time.Hour
 )
 
-// Store is the global session store.
-var Store = &SessionStore{
-	store: map[string]*Entry{},
+// SessionStore keeps the sessions of logged in users by their cookie.
+type SessionStore interface {
+	// Get returns the session if it exists and hasn't expired, counting it as
+	// used. The entry's User isn't filled in, see Load.
+	Get(ctx context.Context, cookie string) (Entry, bool)
+	// Add starts a session for the user, returning the cookie.
+	Add(ctx context.Context, identity Identity) (string, error)
+	Evict(ctx context.Context, cookie string)
+	// Sweep removes the expired sessions, returning how many there were.
+	Sweep(ctx context.Context) (int64, error)
 }
 
+// Store is the global session store. It's in memory unless replaced at
+// startup, which is only suitable for tests and development since a restart
+// logs everyone out.
+var Store SessionStore = NewMemoryStore()
+
+// now is replaced by tests to expire sessions.
+var now = time.Now
+
 func GetEntry(r *http.Request) Entry {
 	sess := r.Context().Value(KeySession)
 	if sess != nil {
@@ -27,39 +51,110 @@ func GetEntry(r *http.Request) Entry {
 	panic("No session found")
 }
 
-type SessionStore struct {
-	sync.RWMutex
-	store map[string]*Entry
+// Identity is who a session belongs to, as GitHub described them at login.
+type Identity struct {
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarUrl string `json:"avatar_url"`
+	Email     string `json:"email"`
 }
 
 type Entry struct {
-	User    *sqlc.GetUserRow
-	Created time.Time
+	Identity Identity
+	// User and Access are the user as they are now, loaded for each request by
+	// the Authenticated pre-handler rather than stored with the session.
+	User     *sqlc.GetUserRow
+	Access   Access
+	Created  time.Time
+	LastSeen time.Time
 }
 
-func (s *SessionStore) Get(cookie string) (Entry, bool) {
-	s.RLock()
-	defer s.RUnlock()
+// Can reports whether the user has the permission. Admins have them all,
+// whatever roles they've been granted.
+func (e Entry) Can(permission string) bool {
+	return e.User.IsAdmin || slices.Contains(e.Access.Permissions, permission)
+}
+
+// Expired reports whether the session has outlived either timeout at t.
+func (e Entry) Expired(t time.Time) bool {
+	return t.Sub(e.Created) >= AbsoluteTimeout || t.Sub(e.LastSeen) >= IdleTimeout
+}
+
+// StartSweeper sweeps the store every interval until ctx is done.
+func StartSweeper(ctx context.Context, store SessionStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			swept, err := store.Sweep(ctx)
+			if err != nil {
+				log.Println("Failed sweeping sessions", err)
+			} else if swept > 0 {
+				log.Println("Swept", swept, "expired sessions")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// MemoryStore keeps sessions in a map, they're lost on restart and aren't
+// shared between instances.
+type MemoryStore struct {
+	sync.Mutex
+	store map[string]*Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{store: map[string]*Entry{}}
+}
+
+func (s *MemoryStore) Get(_ context.Context, cookie string) (Entry, bool) {
+	s.Lock()
+	defer s.Unlock()
 	session, ok := s.store[cookie]
 	if !ok {
 		return Entry{}, false
 	}
+	t := now()
+	if session.Expired(t) {
+		delete(s.store, cookie)
+		return Entry{}, false
+	}
+	session.LastSeen = t
 	return *session, ok
 }
 
-func (s *SessionStore) Add(user *sqlc.GetUserRow) string {
+func (s *MemoryStore) Add(_ context.Context, identity Identity) (string, error) {
 	s.Lock()
 	defer s.Unlock()
 	cookie := GenerateSessionCookie()
+	t := now()
 	s.store[cookie] = &Entry{
-		User:    user,
-		Created: time.Time{},
+		Identity: identity,
+		Created:  t,
+		LastSeen: t,
 	}
-	return cookie
+	return cookie, nil
 }
 
-func (s *SessionStore) Evict(cookie string) {
+func (s *MemoryStore) Evict(_ context.Context, cookie string) {
 	s.Lock()
 	defer s.Unlock()
 	delete(s.store, cookie)
 }
+
+func (s *MemoryStore) Sweep(_ context.Context) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	t := now()
+	var swept int64
+	for cookie, session := range s.store {
+		if session.Expired(t) {
+			delete(s.store, cookie)
+			swept++
+		}
+	}
+	return swept, nil
+}
@@ -0,0 +1,58 @@
+package sessions
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	start := time.Now()
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	ctx := context.Background()
+	store := NewMemoryStore()
+	active, _ := store.Add(ctx, Identity{Login: "bob"})
+	idle, _ := store.Add(ctx, Identity{Login: "alice"})
+
+	// bob keeps using the site, alice doesn't
+	for elapsed := time.Duration(0); elapsed < AbsoluteTimeout; elapsed += IdleTimeout / 2 {
+		now = func() time.Time { return start.Add(elapsed) }
+		if _, ok := store.Get(ctx, active); !ok {
+			t.Fatal("expected bob's session after", elapsed)
+		}
+	}
+	if _, ok := store.Get(ctx, idle); ok {
+		t.Error("expected alice's session to have gone idle")
+	}
+
+	now = func() time.Time { return start.Add(AbsoluteTimeout) }
+	if _, ok := store.Get(ctx, active); ok {
+		t.Error("expected bob's session to have expired")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	start := time.Now()
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.Add(ctx, Identity{Login: "bob"})
+	now = func() time.Time { return start.Add(IdleTimeout / 2) }
+	kept, _ := store.Add(ctx, Identity{Login: "alice"})
+
+	now = func() time.Time { return start.Add(IdleTimeout) }
+	swept, err := store.Sweep(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swept != 1 {
+		t.Error("expected 1 swept, got", swept)
+	}
+	if entry, ok := store.Get(ctx, kept); !ok || entry.Identity.Login != "alice" {
+		t.Error("expected alice's session to be kept", entry, ok)
+	}
+}
@@ -0,0 +1,93 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+// userTTL is how long a loaded user is reused, so a change made elsewhere,
+// such as being granted a role, takes at most this long to apply.
+const userTTL = 30 * time.Second
+
+// Access is what a user has been allowed to do through roles.
+type Access struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+type cachedUser struct {
+	user   sqlc.GetUserRow
+	access Access
+	loaded time.Time
+}
+
+var users = struct {
+	sync.Mutex
+	byLogin map[string]cachedUser
+}{byLogin: map[string]cachedUser{}}
+
+// Load fills in the session's user and their access as they are now. Someone
+// who hasn't been gathered gets what GitHub told us about them at login.
+func Load(ctx context.Context, entry Entry) (Entry, error) {
+	t := now()
+	users.Lock()
+	cached, ok := users.byLogin[entry.Identity.Login]
+	users.Unlock()
+	if !ok || t.Sub(cached.loaded) >= userTTL {
+		var err error
+		if cached, err = load(ctx, entry.Identity); err != nil {
+			return entry, err
+		}
+		cached.loaded = t
+		users.Lock()
+		// expired users are dropped as others load so the cache doesn't grow
+		for login, c := range users.byLogin {
+			if t.Sub(c.loaded) >= userTTL {
+				delete(users.byLogin, login)
+			}
+		}
+		users.byLogin[entry.Identity.Login] = cached
+		users.Unlock()
+	}
+	entry.User = &cached.user
+	entry.Access = cached.access
+	return entry, nil
+}
+
+func load(ctx context.Context, identity Identity) (cachedUser, error) {
+	user, err := db.GetUser(ctx, identity.Login)
+	if err == sql.ErrNoRows {
+		user = sqlc.GetUserRow{
+			Login:     identity.Login,
+			Name:      identity.Name,
+			AvatarUrl: identity.AvatarUrl,
+			Email:     identity.Email,
+		}
+	} else if err != nil {
+		return cachedUser{}, err
+	}
+	roles, permissions, err := db.UserAccess(ctx, identity.Login)
+	if err != nil {
+		return cachedUser{}, err
+	}
+	return cachedUser{user: user, access: Access{Roles: roles, Permissions: permissions}}, nil
+}
+
+// Reload is Load skipping the cache, for responses that must show the user
+// as they are in the database.
+func Reload(ctx context.Context, entry Entry) (Entry, error) {
+	Forget(entry.Identity.Login)
+	return Load(ctx, entry)
+}
+
+// Forget drops the cached user, for when this instance changed them.
+func Forget(login string) {
+	users.Lock()
+	defer users.Unlock()
+	delete(users.byLogin, login)
+}
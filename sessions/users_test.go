@@ -0,0 +1,77 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+func TestLoad(t *testing.T) {
+	start := time.Now()
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	var loads int
+	var permissions []string
+	db.GetUser = func(_ context.Context, login string) (sqlc.GetUserRow, error) {
+		loads++
+		return sqlc.GetUserRow{Login: login}, nil
+	}
+	db.UserAccess = func(_ context.Context, login string) ([]string, []string, error) {
+		return []string{}, permissions, nil
+	}
+
+	ctx := context.Background()
+	bob := Entry{Identity: Identity{Login: "bob"}}
+	if entry, err := Load(ctx, bob); err != nil || entry.Can("runs.manage") {
+		t.Fatal(entry, err)
+	}
+
+	// granted elsewhere, which takes until the cached user expires to see
+	permissions = []string{"runs.manage"}
+	if entry, _ := Load(ctx, bob); entry.Can("runs.manage") || loads != 1 {
+		t.Error("expected the cached user", entry, loads)
+	}
+	now = func() time.Time { return start.Add(userTTL) }
+	if entry, _ := Load(ctx, bob); !entry.Can("runs.manage") || loads != 2 {
+		t.Error("expected the user to be reloaded", entry, loads)
+	}
+
+	permissions = nil
+	if entry, _ := Reload(ctx, bob); entry.Can("runs.manage") || loads != 3 {
+		t.Error("expected the user to be reloaded", entry, loads)
+	}
+}
+
+func TestLoadNotGathered(t *testing.T) {
+	db.GetUser = func(_ context.Context, login string) (sqlc.GetUserRow, error) {
+		return sqlc.GetUserRow{}, sql.ErrNoRows
+	}
+	db.UserAccess = func(_ context.Context, login string) ([]string, []string, error) {
+		return []string{}, []string{}, nil
+	}
+	Forget("alice")
+
+	entry, err := Load(context.Background(), Entry{Identity: Identity{Login: "alice", Name: "Alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.User.Login != "alice" || entry.User.Name != "Alice" || entry.User.IsAdmin {
+		t.Error(entry.User)
+	}
+}
+
+func TestCan(t *testing.T) {
+	admin := Entry{User: &sqlc.GetUserRow{IsAdmin: true}}
+	if !admin.Can("roles.manage") {
+		t.Error("expected admins to have every permission")
+	}
+	moderator := Entry{User: &sqlc.GetUserRow{}, Access: Access{Permissions: []string{"users.manage"}}}
+	if !moderator.Can("users.manage") || moderator.Can("roles.manage") {
+		t.Error("expected only the granted permission", moderator.Access)
+	}
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/web/auth"
+)
+
+var Routes = []crud.Spec{{
+	Method:      "GET",
+	Path:        "/audit",
+	PreHandlers: auth.RequirePermission(auth.PermReadAudit),
+	Handler:     List,
+	Description: "Query the log of changes made to devs, roles and orgs, newest first",
+	Tags:        []string{"Audit"},
+	Validate: crud.Validate{
+		Query: crud.Object(map[string]crud.Field{
+			"actor": crud.String().Description("GitHub login of who made the change, or system"),
+			"action": crud.String().Enum(
+				db.ActionHideUser,
+				db.ActionOptOut,
+				db.ActionDeleteUser,
+				db.ActionGrantRole,
+				db.ActionRevokeRole,
+				db.ActionAddOrg,
+				db.ActionAnnotateOrg,
+				db.ActionRemoveOrg,
+			).Description("What was done"),
+			"target": crud.String().Description("GitHub login of the dev or org changed"),
+			"since":  crud.String().Description("Only changes from this date, e.g. 2024-01-31, or time in RFC 3339"),
+			"cursor": crud.String().Description("The next or prev cursor of another page"),
+			"limit":  crud.Integer().Min(1).Max(db.MaxPageSize).Description("Maximum number of items to return"),
+		}),
+	},
+}}
+
+func List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := db.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+	}
+	if since := query.Get("since"); since != "" {
+		var err error
+		if filter.Since, err = parseSince(since); err != nil {
+			http.Error(w, "since must be a date or an RFC 3339 time", 400)
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	page, err := db.NewPageRequest(query.Get("cursor"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if entries := db.Audit(r.Context(), filter, page); entries == nil {
+		http.Error(w, "Failed to list", 500)
+	} else {
+		jsonResponse(w, 200, entries)
+	}
+}
+
+func parseSince(since string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, since); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, since)
+}
+
+func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/db/sqlc"
+)
+
+func TestList(t *testing.T) {
+	var filter db.AuditFilter
+	db.Audit = func(_ context.Context, f db.AuditFilter, page db.PageRequest) *db.Page[sqlc.AggAudit] {
+		filter = f
+		return &db.Page[sqlc.AggAudit]{Items: []sqlc.AggAudit{{Actor: "bob", Action: db.ActionDeleteUser, Target: "alice"}}, Total: 1}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com/audit?target=alice&action=user.delete&since=2024-01-31", nil)
+	List(w, r)
+
+	if w.Result().StatusCode != 200 {
+		t.Error(w.Result().StatusCode)
+	}
+	expected := db.AuditFilter{Action: db.ActionDeleteUser, Target: "alice", Since: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)}
+	if filter != expected {
+		t.Error(filter)
+	}
+}
+
+func TestListInvalidSince(t *testing.T) {
+	db.Audit = func(_ context.Context, f db.AuditFilter, page db.PageRequest) *db.Page[sqlc.AggAudit] {
+		t.Error("unexpected query")
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com/audit?since=yesterday", nil)
+	List(w, r)
+
+	if w.Result().StatusCode != 400 {
+		t.Error(w.Result().StatusCode)
+	}
+}
@@ -3,19 +3,40 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/dghubble/gologin/v2"
 	"github.com/dghubble/gologin/v2/github"
 	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/aggregator"
 	"github.com/jakecoffman/stldevs/config"
 	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/db/sqlc"
 	"github.com/jakecoffman/stldevs/sessions"
 	"golang.org/x/oauth2"
 	oa2gh "golang.org/x/oauth2/github"
 )
 
-func New(cfg *config.Config) []crud.Spec {
+// Permissions are what roles grant, the agg_role_permission table maps them.
+const (
+	// PermManageUsers is hiding, showing and opting out devs other than yourself.
+	PermManageUsers     = "users.manage"
+	PermDeleteUsers     = "users.delete"
+	PermManageOrgs      = "orgs.manage"
+	PermManageOverrides = "overrides.manage"
+	PermManageRuns      = "runs.manage"
+	PermManageRoles     = "roles.manage"
+	PermReadAudit       = "audit.read"
+)
+
+// Includer adds a logged in user to the site who search didn't find, the
+// aggregator implements it.
+type Includer interface {
+	Include(ctx context.Context, login string) error
+}
+
+func New(cfg *config.Config, includer Includer) []crud.Spec {
 	oauth2Config := &oauth2.Config{
 		ClientID:     cfg.GithubClientID,
 		ClientSecret: cfg.GithubClientSecret,
@@ -24,6 +45,7 @@ func New(cfg *config.Config) []crud.Spec {
 	}
 
 	var stateConfig gologin.CookieConfig
+	var cookieConfig sessions.CookieConfig
 	if cfg.Environment == "prod" {
 		oauth2Config.RedirectURL = "https://stldevs.com/stldevs-api/callback"
 		stateConfig = gologin.CookieConfig{
@@ -33,13 +55,15 @@ func New(cfg *config.Config) []crud.Spec {
 			HTTPOnly: true,
 			Secure:   true, // secure only
 		}
+		cookieConfig = sessions.ProdCookieConfig
 	} else {
 		stateConfig = gologin.DebugOnlyCookieConfig
+		cookieConfig = sessions.DebugOnlyCookieConfig
 	}
 
 	loginTags := []string{"Login"}
 
-	success := &sessions.Issuer{}
+	success := &sessions.Issuer{Cookie: cookieConfig}
 	return []crud.Spec{{
 		Method:      "GET",
 		Path:        "/login",
@@ -55,7 +79,7 @@ func New(cfg *config.Config) []crud.Spec {
 	}, {
 		Method:      "GET",
 		Path:        "/logout",
-		Handler:     logout,
+		Handler:     logout(cookieConfig),
 		Description: "Logout of session",
 		Tags:        loginTags,
 	}, {
@@ -70,13 +94,21 @@ func New(cfg *config.Config) []crud.Spec {
 		Path:        "/me",
 		PreHandlers: Authenticated,
 		Handler:     updateMe,
-		Description: "Get info about the logged in user",
+		Description: "Show or hide the logged in user, or opt them out of being listed for good",
 		Tags:        loginTags,
 		Validate: crud.Validate{
 			Body: crud.Object(map[string]crud.Field{
-				"Hide": crud.Boolean().Required(),
+				"Hide":   crud.Boolean(),
+				"OptOut": crud.Boolean().Description("Never list or gather this user again, until set back to false"),
 			}),
 		},
+	}, {
+		Method:      "POST",
+		Path:        "/me/include",
+		PreHandlers: Authenticated,
+		Handler:     includeMe(includer),
+		Description: "Ask to be listed even if your GitHub location didn't match",
+		Tags:        loginTags,
 	}}
 }
 
@@ -87,25 +119,58 @@ func Authenticated(next http.Handler) http.Handler {
 			http.Error(w, "Not logged in", 401)
 			return
 		}
-		session, ok := sessions.Store.Get(cookie.Value)
+		session, ok := sessions.Store.Get(r.Context(), cookie.Value)
 		if !ok {
 			http.Error(w, "Not logged in", 401)
 			return
 		}
+		session, err = sessions.Load(r.Context(), session)
+		if err != nil {
+			http.Error(w, "Failed to load user", 500)
+			return
+		}
 		ctx := context.WithValue(r.Context(), sessions.KeySession, session)
+		// anything changed from here is audited as done by the user
+		ctx = db.WithActor(ctx, session.User.Login)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RequirePermission is Authenticated for users who have the permission,
+// everyone else is forbidden.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return Authenticated(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !sessions.GetEntry(r).Can(permission) {
+				http.Error(w, "Missing permission "+permission, 403)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// Me is the logged in user with what they're allowed to do.
+type Me struct {
+	*sqlc.GetUserRow
+	sessions.Access
+}
+
 func me(w http.ResponseWriter, r *http.Request) {
-	jsonResponse(w, 200, sessions.GetEntry(r).User)
+	session, err := sessions.Reload(r.Context(), sessions.GetEntry(r))
+	if err != nil {
+		http.Error(w, "Failed to load user", 500)
+		return
+	}
+	jsonResponse(w, 200, Me{session.User, session.Access})
 }
 
 type UpdateUser struct {
-	Hide bool
+	Hide   *bool
+	OptOut *bool
 }
 
-// Patch allows users to show or hide themselves in the site.
+// Patch allows users to show or hide themselves in the site, or to opt out.
 // This is specifically for the /you page because it sends the same response back.
 func updateMe(w http.ResponseWriter, r *http.Request) {
 	session := sessions.GetEntry(r)
@@ -115,32 +180,59 @@ func updateMe(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to bind command object. Are you sending JSON? "+err.Error(), 400)
 		return
 	}
-	err := db.HideUser(cmd.Hide, session.User.Login)
+	if cmd.Hide == nil && cmd.OptOut == nil {
+		http.Error(w, "provide Hide or OptOut", 400)
+		return
+	}
+	if cmd.OptOut != nil {
+		if err := db.SetOptOut(r.Context(), session.User.Login, *cmd.OptOut, session.User.Login); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+	if cmd.Hide != nil {
+		if err := db.HideUser(r.Context(), *cmd.Hide, session.User.Login); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+	session, err := sessions.Reload(r.Context(), session)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	session.User.Hide = cmd.Hide
-	jsonResponse(w, 200, session.User)
+	jsonResponse(w, 200, Me{session.User, session.Access})
 }
 
-func logout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie(sessions.Cookie)
-	if err != nil {
-		jsonResponse(w, 200, "already logged out")
-		return
+// includeMe opts the logged in user in and queues them to be gathered now
+// rather than waiting for the next run.
+func includeMe(includer Includer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := sessions.GetEntry(r)
+		err := includer.Include(r.Context(), session.User.Login)
+		if errors.Is(err, aggregator.ErrOptedOut) {
+			http.Error(w, "You opted out of being listed, opt back in first", 409)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		jsonResponse(w, 202, "queued")
+	}
+}
+
+func logout(cookieConfig sessions.CookieConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessions.Cookie)
+		if err != nil {
+			jsonResponse(w, 200, "already logged out")
+			return
+		}
+		sessions.Store.Evict(r.Context(), cookie.Value)
+		http.SetCookie(w, cookieConfig.Expired())
+		jsonResponse(w, 200, "logged out")
 	}
-	sessions.Store.Evict(cookie.Value)
-	http.SetCookie(w, &http.Cookie{
-		Name:     sessions.Cookie,
-		Value:    "",
-		MaxAge:   -1,
-		Path:     "/",
-		Domain:   "stldevs.com",
-		HttpOnly: true,
-		Secure:   true,
-	})
-	jsonResponse(w, 200, "logged out")
 }
 
 func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jakecoffman/stldevs/aggregator"
+	"github.com/jakecoffman/stldevs/db/sqlc"
+	"github.com/jakecoffman/stldevs/sessions"
+)
+
+type includerFunc func(login string) error
+
+func (f includerFunc) Include(_ context.Context, login string) error {
+	return f(login)
+}
+
+func TestIncludeMe(t *testing.T) {
+	var included string
+	handler := includeMe(includerFunc(func(login string) error {
+		included = login
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "http://example.com/me/include", nil)
+	ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
+		User:    &sqlc.GetUserRow{Login: "bob"},
+		Created: time.Now(),
+	})
+	handler(w, r.WithContext(ctx))
+
+	if included != "bob" {
+		t.Error(included)
+	}
+	if w.Result().StatusCode != 202 {
+		t.Error(w.Result().StatusCode)
+	}
+}
+
+func TestIncludeMeOptedOut(t *testing.T) {
+	handler := includeMe(includerFunc(func(login string) error {
+		return fmt.Errorf("including %v: %w", login, aggregator.ErrOptedOut)
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "http://example.com/me/include", nil)
+	ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
+		User:    &sqlc.GetUserRow{Login: "bob"},
+		Created: time.Now(),
+	})
+	handler(w, r.WithContext(ctx))
+
+	if w.Result().StatusCode != 409 {
+		t.Error(w.Result().StatusCode)
+	}
+}
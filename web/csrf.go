@@ -0,0 +1,58 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/config"
+)
+
+// newCrossOriginProtection rejects requests that browsers made from other
+// sites, which would otherwise carry the session cookie. Browsers say where a
+// request came from in Sec-Fetch-Site or Origin, requests with neither aren't
+// from a browser and so can't be forged this way.
+func newCrossOriginProtection(cfg *config.Config) (*http.CrossOriginProtection, error) {
+	protection := http.NewCrossOriginProtection()
+	for _, origin := range cfg.TrustedOrigins {
+		if err := protection.AddTrustedOrigin(origin); err != nil {
+			return nil, err
+		}
+	}
+	protection.SetDenyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Cross origin requests aren't allowed", 403)
+	}))
+	return protection, nil
+}
+
+// protect puts the protection in front of the pre-handlers of every spec that
+// changes something, so no handler has to check for itself.
+func protect(protection *http.CrossOriginProtection, specs []crud.Spec) []crud.Spec {
+	protected := make([]crud.Spec, len(specs))
+	for i, spec := range specs {
+		switch strings.ToUpper(spec.Method) {
+		case "GET", "HEAD", "OPTIONS":
+		default:
+			spec.PreHandlers = prepend(protection.Handler, spec.PreHandlers)
+		}
+		protected[i] = spec
+	}
+	return protected
+}
+
+// prepend adds a pre-handler before the others, which can be any of the
+// forms crud accepts.
+func prepend(first crud.MiddlewareFunc, preHandlers interface{}) interface{} {
+	switch v := preHandlers.(type) {
+	case nil:
+		return first
+	case []crud.MiddlewareFunc:
+		return append([]crud.MiddlewareFunc{first}, v...)
+	case crud.MiddlewareFunc:
+		return []crud.MiddlewareFunc{first, v}
+	case func(http.Handler) http.Handler:
+		return []crud.MiddlewareFunc{first, v}
+	}
+	// crud refuses to install anything else
+	return preHandlers
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/config"
+)
+
+func TestProtect(t *testing.T) {
+	protection, err := newCrossOriginProtection(&config.Config{TrustedOrigins: []string{"http://localhost:3000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var preHandled int
+	preHandler := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			preHandled++
+			next.ServeHTTP(w, r)
+		})
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	adapter := crud.NewServeMuxAdapter()
+	router := crud.NewRouter("test", "1.0.0", adapter)
+	err = router.Add(protect(protection, []crud.Spec{{
+		Method:  "GET",
+		Path:    "/things",
+		Handler: ok,
+	}, {
+		Method:      "DELETE",
+		Path:        "/things",
+		PreHandlers: preHandler,
+		Handler:     ok,
+	}})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		method, site, origin string
+		expected             int
+	}{
+		{"GET", "cross-site", "https://evil.example", 200},
+		{"DELETE", "cross-site", "https://evil.example", 403},
+		{"DELETE", "same-origin", "", 200},
+		{"DELETE", "same-site", "http://localhost:3000", 200},
+		{"DELETE", "", "", 200}, // not from a browser
+	} {
+		r := httptest.NewRequest(test.method, "http://example.com/things", nil)
+		if test.site != "" {
+			r.Header.Set("Sec-Fetch-Site", test.site)
+		}
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		w := httptest.NewRecorder()
+		adapter.Engine.ServeHTTP(w, r)
+		if w.Code != test.expected {
+			t.Errorf("%v from %v %v: expected %v, got %v", test.method, test.site, test.origin, test.expected, w.Code)
+		}
+	}
+	if preHandled != 3 {
+		t.Error("expected the spec's own pre-handler to still run, ran", preHandled)
+	}
+}
@@ -3,6 +3,7 @@ package dev
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/jakecoffman/crud"
 	"github.com/jakecoffman/stldevs/db"
@@ -22,6 +23,8 @@ var Routes = []crud.Spec{{
 			"type":    crud.String().Description("Type of dev"),
 			"company": crud.String().Description("Company"),
 			"sort":    crud.String().Description("Sort by: stars (default), forks, followers, or public_repos"),
+			"cursor":  crud.String().Description("The next or prev cursor of another page"),
+			"limit":   crud.Integer().Min(1).Max(db.MaxPageSize).Description("Maximum number of items to return"),
 		}),
 	},
 }, {
@@ -47,13 +50,14 @@ var Routes = []crud.Spec{{
 			"login": crud.String().Required().Description("GitHub login"),
 		}),
 		Body: crud.Object(map[string]crud.Field{
-			"hide": crud.Boolean().Required(),
+			"hide":    crud.Boolean(),
+			"opt_out": crud.Boolean().Description("Never list or gather this dev again, until set back to false"),
 		}),
 	},
 }, {
 	Method:      "DELETE",
 	Path:        "/devs/{login}",
-	PreHandlers: auth.Authenticated,
+	PreHandlers: auth.RequirePermission(auth.PermDeleteUsers),
 	Handler:     Delete,
 	Description: "Delete a dev profile",
 	Tags:        []string{"Devs"},
@@ -69,6 +73,8 @@ type ListQuery struct {
 	Type    string `form:"type"`
 	Company string `form:"company"`
 	Sort    string `form:"sort"`
+	Cursor  string `form:"cursor"`
+	Limit   int    `form:"limit"`
 }
 
 func List(w http.ResponseWriter, r *http.Request) {
@@ -82,12 +88,23 @@ func List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, err := db.NewPageRequest(r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
 	if q != "" {
-		jsonResponse(w, 200, db.SearchUsers(q))
+		if results := db.SearchUsers(r.Context(), q, page); results == nil {
+			http.Error(w, "Failed to search", 500)
+		} else {
+			jsonResponse(w, 200, results)
+		}
 		return
 	}
 
-	if listing := db.PopularDevs(typ, company, sort); listing == nil {
+	if listing := db.PopularDevs(r.Context(), typ, company, sort, page); listing == nil {
 		http.Error(w, "Failed to list", 500)
 	} else {
 		jsonResponse(w, 200, listing)
@@ -95,7 +112,7 @@ func List(w http.ResponseWriter, r *http.Request) {
 }
 
 func Get(w http.ResponseWriter, r *http.Request) {
-	profile, err := db.Profile(r.PathValue("login"))
+	profile, err := db.Profile(r.Context(), r.PathValue("login"))
 	if err != nil {
 		http.Error(w, "Failed to find user", 404)
 		return
@@ -104,53 +121,74 @@ func Get(w http.ResponseWriter, r *http.Request) {
 }
 
 type UpdateUser struct {
-	Hide bool `json:"hide"`
+	Hide   *bool `json:"hide"`
+	OptOut *bool `json:"opt_out"`
 }
 
-// Patch allows users and admins show or hide themselves in the site
+// Patch allows users show or hide themselves in the site, or opt out of it
+// altogether, and moderators to do it for them. Logins that were deleted, or
+// never gathered, can still be opted out so no run gathers them.
 func Patch(w http.ResponseWriter, r *http.Request) {
 	login := r.PathValue("login")
 	session := sessions.GetEntry(r)
-	if session.User.IsAdmin == false && session.User.Login != login {
+	if session.User.Login != login && !session.Can(auth.PermManageUsers) {
 		http.Error(w, "Users can only modify themselves", 403)
 		return
 	}
 
-	profile, err := db.Profile(login)
-	if err != nil || profile == nil {
-		http.Error(w, "Failed to find user", 404)
-		return
-	}
 	var cmd UpdateUser
-	if err = json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
 		http.Error(w, "Failed to bind command object. Are you sending JSON? "+err.Error(), 400)
 		return
 	}
-	err = db.HideUser(cmd.Hide, profile.User.Login)
-	if err != nil {
-		http.Error(w, err.Error(), 500)
+	if cmd.Hide == nil && cmd.OptOut == nil {
+		http.Error(w, "provide hide or opt_out", 400)
 		return
 	}
-	profile.User.Hide = cmd.Hide
-	session.User.Hide = cmd.Hide
+	profile, err := db.Profile(r.Context(), login)
+	if err != nil || profile == nil {
+		// without a profile there is nothing to hide, but the login can still opt out
+		if cmd.Hide != nil {
+			http.Error(w, "Failed to find user", 404)
+			return
+		}
+		if err = db.SetOptOut(r.Context(), login, *cmd.OptOut, session.User.Login); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		sessions.Forget(login)
+		jsonResponse(w, 200, map[string]interface{}{"login": login, "opted_out": *cmd.OptOut})
+		return
+	}
+	if cmd.OptOut != nil {
+		if err = db.SetOptOut(r.Context(), profile.User.Login, *cmd.OptOut, session.User.Login); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		profile.User.OptedOut = *cmd.OptOut
+		profile.User.Hide = *cmd.OptOut
+	}
+	if cmd.Hide != nil {
+		if err = db.HideUser(r.Context(), *cmd.Hide, profile.User.Login); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		profile.User.Hide = *cmd.Hide
+	}
+	sessions.Forget(profile.User.Login)
 	jsonResponse(w, 200, profile)
 }
 
-// Delete allows admins to easily expunge old data
+// Delete allows admins and moderators to easily expunge old data
 func Delete(w http.ResponseWriter, r *http.Request) {
-	session := sessions.GetEntry(r)
-	if session.User.IsAdmin == false {
-		http.Error(w, "Only admins can delete users", 403)
-		return
-	}
-
 	login := r.PathValue("login")
 
-	err := db.Delete(login)
+	err := db.Delete(r.Context(), login)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
+	sessions.Forget(login)
 
 	jsonResponse(w, 200, "deleted")
 }
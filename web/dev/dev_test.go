@@ -12,22 +12,26 @@ import (
 	"github.com/jakecoffman/stldevs/db"
 	"github.com/jakecoffman/stldevs/db/sqlc"
 	"github.com/jakecoffman/stldevs/sessions"
+	"github.com/jakecoffman/stldevs/web/auth"
 
 	"github.com/jakecoffman/crud"
 )
 
 func TestList(t *testing.T) {
 	var called bool
-	db.PopularDevs = func(devType, company, sortBy string) []sqlc.PopularDevsRow {
+	db.PopularDevs = func(_ context.Context, devType, company, sortBy string, page db.PageRequest) *db.Page[sqlc.PopularDevsRow] {
 		called = true
 		if devType != "User" {
 			t.Error()
 		}
-		return []sqlc.PopularDevsRow{}
+		if page.Limit != 10 {
+			t.Error(page)
+		}
+		return &db.Page[sqlc.PopularDevsRow]{}
 	}
 
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest("GET", "http://example.com?type=User", nil)
+	r := httptest.NewRequest("GET", "http://example.com?type=User&limit=10", nil)
 	List(w, r)
 
 	if !called {
@@ -40,7 +44,7 @@ func TestList(t *testing.T) {
 
 func TestListFailure(t *testing.T) {
 	var called bool
-	db.PopularDevs = func(devType, company, sortBy string) []sqlc.PopularDevsRow {
+	db.PopularDevs = func(_ context.Context, devType, company, sortBy string, page db.PageRequest) *db.Page[sqlc.PopularDevsRow] {
 		called = true
 		return nil
 	}
@@ -59,12 +63,12 @@ func TestListFailure(t *testing.T) {
 
 func TestSearch(t *testing.T) {
 	var called bool
-	db.SearchUsers = func(term string) []sqlc.SearchUsersRow {
+	db.SearchUsers = func(_ context.Context, term string, page db.PageRequest) *db.Page[sqlc.SearchUsersRow] {
 		called = true
 		if term != "term" {
 			t.Error(term)
 		}
-		return []sqlc.SearchUsersRow{}
+		return &db.Page[sqlc.SearchUsersRow]{}
 	}
 
 	w := httptest.NewRecorder()
@@ -79,9 +83,24 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestListInvalidCursor(t *testing.T) {
+	db.PopularDevs = func(_ context.Context, devType, company, sortBy string, page db.PageRequest) *db.Page[sqlc.PopularDevsRow] {
+		t.Error("should not list with an invalid cursor")
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com?type=User&cursor=bogus", nil)
+	List(w, r)
+
+	if w.Result().StatusCode != 400 {
+		t.Error(w.Result().StatusCode)
+	}
+}
+
 func TestGet(t *testing.T) {
 	var called bool
-	db.Profile = func(name string) (*db.ProfileData, error) {
+	db.Profile = func(_ context.Context, name string) (*db.ProfileData, error) {
 		called = true
 		if name != "bob" {
 			t.Error(name)
@@ -104,7 +123,7 @@ func TestGet(t *testing.T) {
 
 func TestGet404(t *testing.T) {
 	var called bool
-	db.Profile = func(name string) (*db.ProfileData, error) {
+	db.Profile = func(_ context.Context, name string) (*db.ProfileData, error) {
 		called = true
 		return nil, fmt.Errorf("")
 	}
@@ -128,14 +147,14 @@ func TestPatchByUser(t *testing.T) {
 	}
 
 	var called int
-	db.Profile = func(name string) (*db.ProfileData, error) {
+	db.Profile = func(_ context.Context, name string) (*db.ProfileData, error) {
 		called++
 		if name != "bob" {
 			t.Error()
 		}
 		return &db.ProfileData{User: *user}, nil
 	}
-	db.HideUser = func(hide bool, login string) error {
+	db.HideUser = func(_ context.Context, hide bool, login string) error {
 		called++
 		if hide != true && login != "bob" {
 			t.Error(hide, login)
@@ -162,6 +181,96 @@ func TestPatchByUser(t *testing.T) {
 	}
 }
 
+func TestPatchOptOut(t *testing.T) {
+	user := &sqlc.GetUserRow{
+		Login: "bob",
+	}
+
+	db.Profile = func(_ context.Context, name string) (*db.ProfileData, error) {
+		return &db.ProfileData{User: *user}, nil
+	}
+	var optedOut, requestedBy string
+	db.SetOptOut = func(_ context.Context, login string, optOut bool, by string) error {
+		if !optOut {
+			t.Error(optOut)
+		}
+		optedOut, requestedBy = login, by
+		return nil
+	}
+	db.HideUser = func(_ context.Context, hide bool, login string) error {
+		t.Error("unexpected hide")
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	buf := bytes.NewBufferString(`{"opt_out":true}`)
+	r := httptest.NewRequest("PATCH", "http://example.com", buf)
+	r.SetPathValue("login", "bob")
+	ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
+		User:    user,
+		Created: time.Now(),
+	})
+	r = r.WithContext(ctx)
+	Patch(w, r)
+
+	if w.Result().StatusCode != 200 {
+		t.Error(w.Result().StatusCode)
+	}
+	if optedOut != "bob" || requestedBy != "bob" {
+		t.Error(optedOut, requestedBy)
+	}
+}
+
+func TestPatchNothing(t *testing.T) {
+	user := &sqlc.GetUserRow{
+		Login: "bob",
+	}
+	db.Profile = func(_ context.Context, name string) (*db.ProfileData, error) {
+		return &db.ProfileData{User: *user}, nil
+	}
+
+	w := httptest.NewRecorder()
+	buf := bytes.NewBufferString(`{}`)
+	r := httptest.NewRequest("PATCH", "http://example.com", buf)
+	r.SetPathValue("login", "bob")
+	ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
+		User:    user,
+		Created: time.Now(),
+	})
+	r = r.WithContext(ctx)
+	Patch(w, r)
+
+	if w.Result().StatusCode != 400 {
+		t.Error(w.Result().StatusCode)
+	}
+}
+
+func TestPatchByModerator(t *testing.T) {
+	db.Profile = func(_ context.Context, name string) (*db.ProfileData, error) {
+		return &db.ProfileData{User: sqlc.GetUserRow{Login: name}}, nil
+	}
+	var hidden string
+	db.HideUser = func(_ context.Context, hide bool, login string) error {
+		hidden = login
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	buf := bytes.NewBufferString(`{"hide":true}`)
+	r := httptest.NewRequest("PATCH", "http://example.com", buf)
+	r.SetPathValue("login", "alice")
+	ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
+		User:    &sqlc.GetUserRow{Login: "bob"},
+		Access:  sessions.Access{Roles: []string{"moderator"}, Permissions: []string{auth.PermManageUsers}},
+		Created: time.Now(),
+	})
+	Patch(w, r.WithContext(ctx))
+
+	if w.Result().StatusCode != 200 || hidden != "alice" {
+		t.Error(w.Result().StatusCode, hidden)
+	}
+}
+
 func TestPatch403(t *testing.T) {
 	user := &sqlc.GetUserRow{
 		Login: "bob",
@@ -184,7 +293,7 @@ func TestPatch403(t *testing.T) {
 }
 
 func TestPatchAdmin404(t *testing.T) {
-	db.Profile = func(name string) (*db.ProfileData, error) {
+	db.Profile = func(_ context.Context, name string) (*db.ProfileData, error) {
 		if name != "alice" {
 			t.Error()
 		}
@@ -212,13 +321,42 @@ func TestPatchAdmin404(t *testing.T) {
 	}
 }
 
+func TestPatchOptOutNotGathered(t *testing.T) {
+	db.Profile = func(_ context.Context, name string) (*db.ProfileData, error) {
+		return nil, fmt.Errorf("not found")
+	}
+	var optedOut string
+	db.SetOptOut = func(_ context.Context, login string, optOut bool, by string) error {
+		if !optOut || by != "bob" {
+			t.Error(optOut, by)
+		}
+		optedOut = login
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	buf := bytes.NewBufferString(`{"opt_out":true}`)
+	r := httptest.NewRequest("PATCH", "http://example.com", buf)
+	r.SetPathValue("login", "alice")
+	ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
+		User:    &sqlc.GetUserRow{Login: "bob"},
+		Access:  sessions.Access{Roles: []string{"moderator"}, Permissions: []string{auth.PermManageUsers}},
+		Created: time.Now(),
+	})
+	Patch(w, r.WithContext(ctx))
+
+	if w.Result().StatusCode != 200 || optedOut != "alice" {
+		t.Error(w.Result().StatusCode, optedOut)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	user := &sqlc.GetUserRow{
 		Login:   "bob",
 		IsAdmin: true,
 	}
 
-	db.Delete = func(login string) error {
+	db.Delete = func(_ context.Context, login string) error {
 		if login != "alice" {
 			t.Errorf("%s", login)
 		}
@@ -242,31 +380,44 @@ func TestDelete(t *testing.T) {
 }
 
 func TestDeleteAccessDenied(t *testing.T) {
-	user := &sqlc.GetUserRow{
-		Login:   "bob",
-		IsAdmin: false,
+	adapter := crud.NewServeMuxAdapter()
+	r := crud.NewRouter("test", "1.0.0", adapter)
+	if err := r.Add(Routes...); err != nil {
+		t.Fatal(err)
 	}
+	cookie, _ := sessions.Store.Add(context.Background(), sessions.Identity{Login: "bob"})
+	sessions.Forget("bob")
 
-	db.Delete = func(login string) error {
-		if login != "alice" {
-			t.Errorf("%s", login)
-		}
+	var permissions []string
+	db.GetUser = func(_ context.Context, login string) (sqlc.GetUserRow, error) {
+		return sqlc.GetUserRow{Login: login}, nil
+	}
+	db.UserAccess = func(_ context.Context, login string) ([]string, []string, error) {
+		return []string{}, permissions, nil
+	}
+	var deleted int
+	db.Delete = func(_ context.Context, login string) error {
+		deleted++
 		return nil
 	}
 
+	req := httptest.NewRequest("DELETE", "/devs/alice", nil)
+	req.AddCookie(&http.Cookie{Name: sessions.Cookie, Value: cookie})
 	w := httptest.NewRecorder()
-	buf := bytes.NewBufferString(`{}`)
-	r := httptest.NewRequest("GET", "http://example.com", buf)
-	r.SetPathValue("login", "alice") // bob != alice
-	ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
-		User:    user,
-		Created: time.Now(),
-	})
-	r = r.WithContext(ctx)
-	Delete(w, r)
+	adapter.Engine.ServeHTTP(w, req)
+	if w.Code != 403 || deleted != 0 {
+		t.Error(w.Code, deleted)
+	}
 
-	if w.Result().StatusCode != 403 {
-		t.Error(w.Result().StatusCode)
+	// made a moderator
+	permissions = []string{auth.PermDeleteUsers}
+	sessions.Forget("bob")
+	req = httptest.NewRequest("DELETE", "/devs/alice", nil)
+	req.AddCookie(&http.Cookie{Name: sessions.Cookie, Value: cookie})
+	w = httptest.NewRecorder()
+	adapter.Engine.ServeHTTP(w, req)
+	if w.Code != 200 || deleted != 1 {
+		t.Error(w.Code, deleted)
 	}
 }
 
@@ -305,13 +456,20 @@ func TestRoutes_Validation(t *testing.T) {
 	}
 
 	// Mock session
-	cookie := sessions.Store.Add(&sqlc.GetUserRow{Login: "bob"})
+	cookie, _ := sessions.Store.Add(context.Background(), sessions.Identity{Login: "bob"})
 
 	// Mock DB
-	db.Profile = func(login string) (*db.ProfileData, error) {
+	sessions.Forget("bob")
+	db.GetUser = func(_ context.Context, login string) (sqlc.GetUserRow, error) {
+		return sqlc.GetUserRow{Login: login}, nil
+	}
+	db.UserAccess = func(_ context.Context, login string) ([]string, []string, error) {
+		return []string{}, []string{}, nil
+	}
+	db.Profile = func(_ context.Context, login string) (*db.ProfileData, error) {
 		return &db.ProfileData{User: sqlc.GetUserRow{Login: "bob"}}, nil
 	}
-	db.HideUser = func(hide bool, login string) error {
+	db.HideUser = func(_ context.Context, hide bool, login string) error {
 		return nil
 	}
 
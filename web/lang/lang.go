@@ -24,8 +24,8 @@ var Routes = []crud.Spec{{
 	Tags:        []string{"Languages"},
 	Validate: crud.Validate{
 		Query: crud.Object(map[string]crud.Field{
-			"limit":  crud.Number().Min(1).Max(25).Description("Maximum number of items to return"),
-			"offset": crud.Number().Min(0).Description("Number of entries to skip"),
+			"cursor": crud.String().Description("The next or prev cursor of another page"),
+			"limit":  crud.Integer().Min(1).Max(db.MaxPageSize).Description("Maximum number of items to return"),
 		}),
 		Path: crud.Object(map[string]crud.Field{
 			"lang": crud.String().Required().Description("The language name"),
@@ -34,39 +34,21 @@ var Routes = []crud.Spec{{
 }}
 
 func List(w http.ResponseWriter, r *http.Request) {
-	jsonResponse(w, 200, db.PopularLanguages())
+	jsonResponse(w, 200, db.PopularLanguages(r.Context()))
 }
 
 func Get(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-	limit, _ := strconv.Atoi(limitStr)
-	offset, _ := strconv.Atoi(offsetStr)
-
-	if limit <= 0 {
-		limit = 25
-	}
-	if offset < 0 {
-		offset = 0
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, err := db.NewPageRequest(r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
 	}
-
-	lang := r.PathValue("lang")
-	langs := db.Language(lang)
-
-	if limit+offset > len(langs) {
-		limit = len(langs)
+	if langs := db.Language(r.Context(), r.PathValue("lang"), page); langs == nil {
+		http.Error(w, "Failed to list", 500)
 	} else {
-		limit += offset
-	}
-	if offset > len(langs) {
-		limit = 0
-		offset = 0
+		jsonResponse(w, 200, langs)
 	}
-	jsonResponse(w, 200, map[string]interface{}{
-		"languages": langs[offset:limit],
-		"count":     len(langs),
-		"language":  lang,
-	})
 }
 
 func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
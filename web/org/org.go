@@ -0,0 +1,123 @@
+package org
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/sessions"
+	"github.com/jakecoffman/stldevs/web/auth"
+)
+
+var Routes = []crud.Spec{{
+	Method:      "GET",
+	Path:        "/orgs",
+	PreHandlers: auth.RequirePermission(auth.PermManageOrgs),
+	Handler:     List,
+	Description: "List the registered organizations",
+	Tags:        []string{"Orgs"},
+}, {
+	Method:      "POST",
+	Path:        "/orgs",
+	PreHandlers: auth.RequirePermission(auth.PermManageOrgs),
+	Handler:     Add,
+	Description: "Register an organization so every run includes it",
+	Tags:        []string{"Orgs"},
+	Validate: crud.Validate{
+		Body: crud.Object(map[string]crud.Field{
+			"login":  crud.String().Required().Description("GitHub login of the organization"),
+			"reason": crud.String().Description("Why the organization is tracked"),
+		}),
+	},
+}, {
+	Method:      "PATCH",
+	Path:        "/orgs/{login}",
+	PreHandlers: auth.RequirePermission(auth.PermManageOrgs),
+	Handler:     Patch,
+	Description: "Annotate a registered organization",
+	Tags:        []string{"Orgs"},
+	Validate: crud.Validate{
+		Path: crud.Object(map[string]crud.Field{
+			"login": crud.String().Required().Description("GitHub login of the organization"),
+		}),
+		Body: crud.Object(map[string]crud.Field{
+			"reason": crud.String().Required().Description("Why the organization is tracked"),
+		}),
+	},
+}, {
+	Method:      "DELETE",
+	Path:        "/orgs/{login}",
+	PreHandlers: auth.RequirePermission(auth.PermManageOrgs),
+	Handler:     Delete,
+	Description: "Stop tracking an organization",
+	Tags:        []string{"Orgs"},
+	Validate: crud.Validate{
+		Path: crud.Object(map[string]crud.Field{
+			"login": crud.String().Required().Description("GitHub login of the organization"),
+		}),
+	},
+}}
+
+func List(w http.ResponseWriter, r *http.Request) {
+	if orgs := db.Orgs(r.Context()); orgs == nil {
+		http.Error(w, "Failed to list", 500)
+	} else {
+		jsonResponse(w, 200, orgs)
+	}
+}
+
+type AddOrg struct {
+	Login  string `json:"login"`
+	Reason string `json:"reason"`
+}
+
+func Add(w http.ResponseWriter, r *http.Request) {
+	var cmd AddOrg
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		http.Error(w, "Failed to bind command object. Are you sending JSON? "+err.Error(), 400)
+		return
+	}
+	err := db.AddOrg(r.Context(), cmd.Login, cmd.Reason, sessions.GetEntry(r).User.Login)
+	if errors.Is(err, db.ErrOrgRegistered) {
+		http.Error(w, err.Error(), 409)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	jsonResponse(w, 201, cmd)
+}
+
+type PatchOrg struct {
+	Reason string `json:"reason"`
+}
+
+func Patch(w http.ResponseWriter, r *http.Request) {
+	var cmd PatchOrg
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		http.Error(w, "Failed to bind command object. Are you sending JSON? "+err.Error(), 400)
+		return
+	}
+	if err := db.AnnotateOrg(r.Context(), r.PathValue("login"), cmd.Reason); err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	jsonResponse(w, 200, cmd)
+}
+
+func Delete(w http.ResponseWriter, r *http.Request) {
+	if err := db.RemoveOrg(r.Context(), r.PathValue("login")); err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	jsonResponse(w, 200, "deleted")
+}
+
+func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
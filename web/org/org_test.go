@@ -0,0 +1,41 @@
+package org
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/db/sqlc"
+	"github.com/jakecoffman/stldevs/sessions"
+)
+
+func TestAdd(t *testing.T) {
+	db.AddOrg = func(_ context.Context, login, reason, by string) error {
+		switch login {
+		case "stlorg":
+			return fmt.Errorf("%v is %w", login, db.ErrOrgRegistered)
+		case "broken":
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}
+
+	for login, expected := range map[string]int{"neworg": 201, "stlorg": 409, "broken": 500} {
+		w := httptest.NewRecorder()
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"login":%q}`, login))
+		r := httptest.NewRequest("POST", "http://example.com", buf)
+		ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
+			User:    &sqlc.GetUserRow{Login: "bob", IsAdmin: true},
+			Created: time.Now(),
+		})
+		Add(w, r.WithContext(ctx))
+
+		if w.Result().StatusCode != expected {
+			t.Error(login, w.Result().StatusCode)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package override
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/sessions"
+	"github.com/jakecoffman/stldevs/web/auth"
+)
+
+var Routes = []crud.Spec{{
+	Method:      "GET",
+	Path:        "/overrides",
+	PreHandlers: auth.RequirePermission(auth.PermManageOverrides),
+	Handler:     List,
+	Description: "List the location overrides",
+	Tags:        []string{"Location Overrides"},
+}, {
+	Method:      "PUT",
+	Path:        "/overrides/{login}",
+	PreHandlers: auth.RequirePermission(auth.PermManageOverrides),
+	Handler:     Put,
+	Description: "Include or exclude a dev regardless of their location",
+	Tags:        []string{"Location Overrides"},
+	Validate: crud.Validate{
+		Path: crud.Object(map[string]crud.Field{
+			"login": crud.String().Required().Description("GitHub login"),
+		}),
+		Body: crud.Object(map[string]crud.Field{
+			"include": crud.Boolean().Required().Description("Whether the dev is in the region"),
+			"reason":  crud.String().Description("Why the override exists"),
+		}),
+	},
+}, {
+	Method:      "DELETE",
+	Path:        "/overrides/{login}",
+	PreHandlers: auth.RequirePermission(auth.PermManageOverrides),
+	Handler:     Delete,
+	Description: "Remove a location override",
+	Tags:        []string{"Location Overrides"},
+	Validate: crud.Validate{
+		Path: crud.Object(map[string]crud.Field{
+			"login": crud.String().Required().Description("GitHub login"),
+		}),
+	},
+}}
+
+func List(w http.ResponseWriter, r *http.Request) {
+	if overrides := db.LocationOverrides(r.Context()); overrides == nil {
+		http.Error(w, "Failed to list", 500)
+	} else {
+		jsonResponse(w, 200, overrides)
+	}
+}
+
+type PutOverride struct {
+	Include bool   `json:"include"`
+	Reason  string `json:"reason"`
+}
+
+func Put(w http.ResponseWriter, r *http.Request) {
+	var cmd PutOverride
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		http.Error(w, "Failed to bind command object. Are you sending JSON? "+err.Error(), 400)
+		return
+	}
+	login := r.PathValue("login")
+	if err := db.SetLocationOverride(r.Context(), login, cmd.Include, cmd.Reason, sessions.GetEntry(r).User.Login); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	jsonResponse(w, 200, cmd)
+}
+
+func Delete(w http.ResponseWriter, r *http.Request) {
+	if err := db.DeleteLocationOverride(r.Context(), r.PathValue("login")); err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	jsonResponse(w, 200, "deleted")
+}
+
+func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
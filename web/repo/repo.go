@@ -3,6 +3,7 @@ package repo
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/jakecoffman/crud"
 	"github.com/jakecoffman/stldevs/db"
@@ -16,7 +17,9 @@ var Routes = []crud.Spec{{
 	Tags:        []string{"Repos"},
 	Validate: crud.Validate{
 		Query: crud.Object(map[string]crud.Field{
-			"q": crud.String().Required().Description("Query string"),
+			"q":      crud.String().Required().Description("Query string"),
+			"cursor": crud.String().Description("The next or prev cursor of another page"),
+			"limit":  crud.Integer().Min(1).Max(db.MaxPageSize).Description("Maximum number of items to return"),
 		}),
 	},
 }}
@@ -27,7 +30,17 @@ func List(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "q is a required query parameter", 400)
 		return
 	}
-	jsonResponse(w, 200, db.SearchRepos(q))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, err := db.NewPageRequest(r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if results := db.SearchRepos(r.Context(), q, page); results == nil {
+		http.Error(w, "Failed to search", 500)
+	} else {
+		jsonResponse(w, 200, results)
+	}
 }
 
 func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
@@ -0,0 +1,90 @@
+package role
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/sessions"
+	"github.com/jakecoffman/stldevs/web/auth"
+)
+
+var memberPath = crud.Object(map[string]crud.Field{
+	"role":  crud.String().Required().Description("Name of the role, such as moderator"),
+	"login": crud.String().Required().Description("GitHub login"),
+})
+
+var Routes = []crud.Spec{{
+	Method:      "GET",
+	Path:        "/roles",
+	PreHandlers: auth.RequirePermission(auth.PermManageRoles),
+	Handler:     List,
+	Description: "List the roles with their permissions and who they're granted to",
+	Tags:        []string{"Roles"},
+}, {
+	Method:      "PUT",
+	Path:        "/roles/{role}/members/{login}",
+	PreHandlers: auth.RequirePermission(auth.PermManageRoles),
+	Handler:     Grant,
+	Description: "Grant a role to a dev",
+	Tags:        []string{"Roles"},
+	Validate: crud.Validate{
+		Path: memberPath,
+	},
+}, {
+	Method:      "DELETE",
+	Path:        "/roles/{role}/members/{login}",
+	PreHandlers: auth.RequirePermission(auth.PermManageRoles),
+	Handler:     Revoke,
+	Description: "Revoke a role from a dev",
+	Tags:        []string{"Roles"},
+	Validate: crud.Validate{
+		Path: memberPath,
+	},
+}}
+
+func List(w http.ResponseWriter, r *http.Request) {
+	if roles := db.Roles(r.Context()); roles == nil {
+		http.Error(w, "Failed to list", 500)
+	} else {
+		jsonResponse(w, 200, roles)
+	}
+}
+
+type Member struct {
+	Role  string `json:"role"`
+	Login string `json:"login"`
+}
+
+func Grant(w http.ResponseWriter, r *http.Request) {
+	member := Member{Role: r.PathValue("role"), Login: r.PathValue("login")}
+	err := db.GrantRole(r.Context(), member.Login, member.Role, sessions.GetEntry(r).User.Login)
+	if errors.Is(err, db.ErrUnknownRole) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	sessions.Forget(member.Login)
+	jsonResponse(w, 200, member)
+}
+
+func Revoke(w http.ResponseWriter, r *http.Request) {
+	login := r.PathValue("login")
+	if err := db.RevokeRole(r.Context(), login, r.PathValue("role")); err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	sessions.Forget(login)
+	jsonResponse(w, 200, "revoked")
+}
+
+func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
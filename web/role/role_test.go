@@ -0,0 +1,43 @@
+package role
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/db/sqlc"
+	"github.com/jakecoffman/stldevs/sessions"
+)
+
+func TestGrant(t *testing.T) {
+	var granted, grantedBy string
+	db.GrantRole = func(_ context.Context, login, role, by string) error {
+		if role != "moderator" {
+			return fmt.Errorf("%w %v", db.ErrUnknownRole, role)
+		}
+		granted, grantedBy = login, by
+		return nil
+	}
+
+	for role, expected := range map[string]int{"moderator": 200, "overlord": 404} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "http://example.com", nil)
+		r.SetPathValue("role", role)
+		r.SetPathValue("login", "alice")
+		ctx := context.WithValue(r.Context(), sessions.KeySession, sessions.Entry{
+			User:    &sqlc.GetUserRow{Login: "bob", IsAdmin: true},
+			Created: time.Now(),
+		})
+		Grant(w, r.WithContext(ctx))
+
+		if w.Result().StatusCode != expected {
+			t.Error(role, w.Result().StatusCode)
+		}
+	}
+	if granted != "alice" || grantedBy != "bob" {
+		t.Error(granted, grantedBy)
+	}
+}
@@ -1,31 +1,140 @@
 package run
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/aggregator"
 	"github.com/jakecoffman/stldevs/db"
+	"github.com/jakecoffman/stldevs/web/auth"
 )
 
-var Routes = []crud.Spec{{
-	Method:      "GET",
-	Path:        "/runs",
-	Handler:     List,
-	Description: "Gets the time of the last scrape of GitHub",
-	Tags:        []string{"Last Run"},
-	Validate:    crud.Validate{},
-}}
+// Scheduler runs the aggregator on demand, aggregator.Scheduler implements it.
+type Scheduler interface {
+	Trigger() error
+	Cancel() bool
+	Status(ctx context.Context) (aggregator.SchedulerStatus, error)
+}
 
-var epoch time.Time
+func New(scheduler Scheduler) []crud.Spec {
+	return []crud.Spec{{
+		Method:      "GET",
+		Path:        "/runs",
+		Handler:     List,
+		Description: "Gets the time of the last successful scrape of GitHub and the history of runs",
+		Tags:        []string{"Last Run"},
+		Validate: crud.Validate{
+			Query: crud.Object(map[string]crud.Field{
+				"limit": crud.Number().Min(1).Max(100).Description("Maximum number of runs to return"),
+			}),
+		},
+	}, {
+		Method:      "POST",
+		Path:        "/runs",
+		PreHandlers: auth.RequirePermission(auth.PermManageRuns),
+		Handler:     start(scheduler),
+		Description: "Starts a run of the aggregator",
+		Tags:        []string{"Last Run"},
+	}, {
+		Method:      "GET",
+		Path:        "/runs/current",
+		PreHandlers: auth.RequirePermission(auth.PermManageRuns),
+		Handler:     current(scheduler),
+		Description: "Reports whether a run is in progress",
+		Tags:        []string{"Last Run"},
+	}, {
+		Method:      "DELETE",
+		Path:        "/runs/current",
+		PreHandlers: auth.RequirePermission(auth.PermManageRuns),
+		Handler:     cancel(scheduler),
+		Description: "Cancels the run in progress, which is recorded as aborted",
+		Tags:        []string{"Last Run"},
+	}, {
+		Method:      "GET",
+		Path:        "/runs/{id}",
+		Handler:     Get,
+		Description: "Gets a run with its statistics and errors",
+		Tags:        []string{"Last Run"},
+		Validate: crud.Validate{
+			Path: crud.Object(map[string]crud.Field{
+				"id": crud.Integer().Required().Description("The run id"),
+			}),
+		},
+	}}
+}
 
 func List(w http.ResponseWriter, r *http.Request) {
-	if lastRun := db.LastRun(); lastRun.Year() == epoch.Year() {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	runs := db.Runs(r.Context(), limit)
+	if runs == nil {
 		http.Error(w, "Failed to list", 500)
 		return
-	} else {
-		jsonResponse(w, 200, lastRun)
+	}
+	jsonResponse(w, 200, map[string]interface{}{
+		"last_run": db.LastRun(r.Context()),
+		"runs":     runs,
+	})
+}
+
+func Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid run id", 400)
+		return
+	}
+	run, err := db.Run(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to find run", 404)
+		return
+	}
+	jsonResponse(w, 200, run)
+}
+
+func start(scheduler Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := scheduler.Trigger()
+		if errors.Is(err, aggregator.ErrRunInProgress) {
+			http.Error(w, err.Error(), 409)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		status, err := scheduler.Status(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		jsonResponse(w, 202, status)
+	}
+}
+
+func current(scheduler Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := scheduler.Status(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		jsonResponse(w, 200, status)
+	}
+}
+
+func cancel(scheduler Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !scheduler.Cancel() {
+			http.Error(w, "No run in progress", 404)
+			return
+		}
+		w.WriteHeader(204)
 	}
 }
 
@@ -0,0 +1,58 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/db"
+)
+
+var Routes = []crud.Spec{{
+	Method:      "GET",
+	Path:        "/search",
+	Handler:     Search,
+	Description: "Search users, orgs and repos at once, with counts by language, dev type and company",
+	Tags:        []string{"Search"},
+	Validate: crud.Validate{
+		Query: crud.Object(map[string]crud.Field{
+			"q":        crud.String().Description(`Query string, which may quote "phrases" and use language:, user:, type: and company: qualifiers`),
+			"language": crud.String().Description("Only repos in, and devs with repos in, this language"),
+			"type":     crud.String().Description("Only devs, and repos of devs, of this type"),
+			"company":  crud.String().Description("Only devs, and repos of devs, at this company"),
+		}),
+	},
+}}
+
+// Search takes the facet filters either as qualifiers in q or as their own
+// parameters, the parameters winning when both are given.
+func Search(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := db.ParseSearch(params.Get("q"))
+	if language := params.Get("language"); language != "" {
+		query.Language = language
+	}
+	if typ := params.Get("type"); typ != "" {
+		query.Type = typ
+	}
+	if company := params.Get("company"); company != "" {
+		query.Company = company
+	}
+	if query == (db.SearchQuery{}) {
+		http.Error(w, "provide a q query parameter or a filter", 400)
+		return
+	}
+
+	results, err := db.Search(r.Context(), query)
+	if err != nil {
+		http.Error(w, "Failed to search", 500)
+		return
+	}
+	jsonResponse(w, 200, results)
+}
+
+func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jakecoffman/stldevs/db"
+)
+
+func TestSearch(t *testing.T) {
+	var called bool
+	db.Search = func(_ context.Context, query db.SearchQuery) (*db.SearchResults, error) {
+		called = true
+		expected := db.SearchQuery{Text: `"static site"`, Language: "Rust", User: "bob", Company: "Acme"}
+		if query != expected {
+			t.Errorf("expected %+v, got %+v", expected, query)
+		}
+		return &db.SearchResults{}, nil
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", `http://example.com?q=%22static+site%22+language:go+user:bob&language=Rust&company=Acme`, nil)
+	Search(w, r)
+
+	if !called {
+		t.Error()
+	}
+	if w.Result().StatusCode != 200 {
+		t.Error(w.Result().StatusCode)
+	}
+}
+
+func TestSearchEmpty(t *testing.T) {
+	db.Search = func(_ context.Context, query db.SearchQuery) (*db.SearchResults, error) {
+		t.Error("should not search without a query")
+		return nil, nil
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com?q=+", nil)
+	Search(w, r)
+
+	if w.Result().StatusCode != 400 {
+		t.Error(w.Result().StatusCode)
+	}
+}
+
+func TestSearchFailure(t *testing.T) {
+	db.Search = func(_ context.Context, query db.SearchQuery) (*db.SearchResults, error) {
+		return nil, fmt.Errorf("whoops")
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com?q=go", nil)
+	Search(w, r)
+
+	if w.Result().StatusCode != 500 {
+		t.Error(w.Result().StatusCode)
+	}
+}
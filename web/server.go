@@ -4,25 +4,44 @@ import (
 	"log"
 
 	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/aggregator"
 	"github.com/jakecoffman/stldevs/config"
+	"github.com/jakecoffman/stldevs/web/audit"
 	"github.com/jakecoffman/stldevs/web/auth"
 	"github.com/jakecoffman/stldevs/web/dev"
 	"github.com/jakecoffman/stldevs/web/lang"
+	"github.com/jakecoffman/stldevs/web/org"
+	"github.com/jakecoffman/stldevs/web/override"
 	"github.com/jakecoffman/stldevs/web/repo"
+	"github.com/jakecoffman/stldevs/web/role"
 	"github.com/jakecoffman/stldevs/web/run"
+	"github.com/jakecoffman/stldevs/web/search"
+	"github.com/jakecoffman/stldevs/web/trending"
 )
 
-func Run(cfg *config.Config) {
+func Run(cfg *config.Config, agg *aggregator.Aggregator, scheduler *aggregator.Scheduler) {
 	r := crud.NewRouter("stldevs api", "1.0.0", crud.NewServeMuxAdapter())
 	if cfg.Environment == "prod" {
 		r.Swagger.BasePath = "/stldevs-api/"
 	}
 
-	must(r.Add(auth.New(cfg)...))
-	must(r.Add(repo.Routes...))
-	must(r.Add(run.Routes...))
-	must(r.Add(dev.Routes...))
-	must(r.Add(lang.Routes...))
+	protection, err := newCrossOriginProtection(cfg)
+	must(err)
+	add := func(specs ...crud.Spec) error {
+		return r.Add(protect(protection, specs)...)
+	}
+
+	must(add(auth.New(cfg, agg)...))
+	must(add(repo.Routes...))
+	must(add(run.New(scheduler)...))
+	must(add(dev.Routes...))
+	must(add(lang.Routes...))
+	must(add(override.Routes...))
+	must(add(org.Routes...))
+	must(add(trending.Routes...))
+	must(add(search.Routes...))
+	must(add(role.Routes...))
+	must(add(audit.Routes...))
 
 	log.Println("Serving on http://127.0.0.1:8080")
 	if err := r.Serve("0.0.0.0:8080"); err != nil {
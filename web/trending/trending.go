@@ -0,0 +1,73 @@
+package trending
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jakecoffman/crud"
+	"github.com/jakecoffman/stldevs/db"
+)
+
+var Routes = []crud.Spec{{
+	Method:      "GET",
+	Path:        "/trending/devs",
+	Handler:     Devs,
+	Description: "List the devs gaining the most stars or followers",
+	Tags:        []string{"Trending"},
+	Validate: crud.Validate{
+		Query: crud.Object(map[string]crud.Field{
+			"days":     crud.Integer().Enum(7, 30, 90).Description("Window to measure growth over: 7 (default), 30 or 90 days"),
+			"language": crud.String().Description("Only count repos in this language"),
+			"type":     crud.String().Description("Type of dev"),
+			"sort":     crud.String().Enum("stars", "followers").Description("Sort by: stars (default) or followers"),
+		}),
+	},
+}, {
+	Method:      "GET",
+	Path:        "/trending/repos",
+	Handler:     Repos,
+	Description: "List the repos gaining the most stars",
+	Tags:        []string{"Trending"},
+	Validate: crud.Validate{
+		Query: crud.Object(map[string]crud.Field{
+			"days":     crud.Integer().Enum(7, 30, 90).Description("Window to measure growth over: 7 (default), 30 or 90 days"),
+			"language": crud.String().Description("Language of the repos"),
+			"type":     crud.String().Description("Type of the repo owner"),
+		}),
+	},
+}}
+
+func Devs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	listing := db.TrendingDevs(r.Context(), days(r), query.Get("language"), query.Get("type"), query.Get("sort"))
+	if listing == nil {
+		http.Error(w, "Failed to list", 500)
+		return
+	}
+	jsonResponse(w, 200, listing)
+}
+
+func Repos(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	listing := db.TrendingRepos(r.Context(), days(r), query.Get("language"), query.Get("type"))
+	if listing == nil {
+		http.Error(w, "Failed to list", 500)
+		return
+	}
+	jsonResponse(w, 200, listing)
+}
+
+// days is the growth window asked for, already validated, a week by default.
+func days(r *http.Request) int {
+	if days, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil {
+		return days
+	}
+	return 7
+}
+
+func jsonResponse(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}